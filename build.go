@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"go/build/constraint"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxFreeTags limits number of tags tried by buildSatisfiable, files with more tags are never excluded.
+const maxFreeTags = 16
+
+// knownOS and knownArch list GOOS and GOARCH values recognized in file name suffixes and targets, as in go/build.
+var (
+	knownOS = map[string]struct{}{
+		"aix": {}, "android": {}, "darwin": {}, "dragonfly": {}, "freebsd": {}, "hurd": {}, "illumos": {}, "ios": {},
+		"js": {}, "linux": {}, "nacl": {}, "netbsd": {}, "openbsd": {}, "plan9": {}, "solaris": {}, "wasip1": {},
+		"windows": {}, "zos": {},
+	}
+	knownArch = map[string]struct{}{
+		"386": {}, "amd64": {}, "amd64p32": {}, "arm": {}, "armbe": {}, "arm64": {}, "arm64be": {}, "loong64": {},
+		"mips": {}, "mipsle": {}, "mips64": {}, "mips64le": {}, "mips64p32": {}, "mips64p32le": {}, "ppc": {},
+		"ppc64": {}, "ppc64le": {}, "riscv": {}, "riscv64": {}, "s390": {}, "s390x": {}, "sparc": {}, "sparc64": {},
+		"wasm": {},
+	}
+	// unixOS lists GOOS values which satisfy "unix" build tag.
+	unixOS = map[string]struct{}{
+		"aix": {}, "android": {}, "darwin": {}, "dragonfly": {}, "freebsd": {}, "hurd": {}, "illumos": {}, "ios": {},
+		"linux": {}, "netbsd": {}, "openbsd": {}, "solaris": {},
+	}
+)
+
+// fileBuildConstraint returns build constraint of go file: its //go:build line, or // +build lines joined with AND,
+// together with GOOS and GOARCH implied by the file name. Nil means file has no constraints.
+func fileBuildConstraint(path string) (constraint.Expr, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("fail open file %s: %v", path, err)
+	}
+	defer file.Close()
+
+	var goBuild, plusBuild constraint.Expr
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "package") {
+			break
+		}
+
+		switch {
+		case constraint.IsGoBuild(line):
+			expr, err := constraint.Parse(line)
+			if err != nil {
+				return nil, fmt.Errorf("file %s: %v", path, err)
+			}
+			goBuild = expr
+		case constraint.IsPlusBuild(line):
+			expr, err := constraint.Parse(line)
+			if err != nil {
+				return nil, fmt.Errorf("file %s: %v", path, err)
+			}
+			plusBuild = andExpr(plusBuild, expr)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("fail read file %s: %v", path, err)
+	}
+
+	// //go:build line supersedes // +build lines.
+	expr := goBuild
+	if expr == nil {
+		expr = plusBuild
+	}
+	for _, tag := range fileNameTags(filepath.Base(path)) {
+		expr = andExpr(expr, &constraint.TagExpr{Tag: tag})
+	}
+
+	return expr, nil
+}
+
+// fileNameTags returns GOOS and GOARCH implied by file name suffixes like _linux.go, _amd64.go or _linux_amd64.go.
+func fileNameTags(name string) []string {
+	name = strings.TrimSuffix(name, ".go")
+	name = strings.TrimSuffix(name, "_test")
+	parts := strings.Split(name, "_")
+	// a file named like linux.go or _linux.go has no constraints.
+	if len(parts) < 2 || parts[0] == "" {
+		return nil
+	}
+
+	last := parts[len(parts)-1]
+	if len(parts) >= 3 {
+		if _, ok := knownOS[parts[len(parts)-2]]; ok {
+			if _, ok := knownArch[last]; ok {
+				return []string{parts[len(parts)-2], last}
+			}
+		}
+	}
+	if _, ok := knownOS[last]; ok {
+		return []string{last}
+	}
+	if _, ok := knownArch[last]; ok {
+		return []string{last}
+	}
+
+	return nil
+}
+
+func andExpr(x, y constraint.Expr) constraint.Expr {
+	if x == nil {
+		return y
+	}
+
+	return &constraint.AndExpr{X: x, Y: y}
+}
+
+// buildSatisfiable checks whether expr can be satisfied while all excluded tags are off.
+// Other tags may be either on or off.
+func buildSatisfiable(expr constraint.Expr, excluded map[string]struct{}) bool {
+	var free []string
+	seen := make(map[string]struct{})
+	collectTags(expr, func(tag string) {
+		if _, ok := excluded[tag]; ok {
+			return
+		}
+		if _, ok := seen[tag]; !ok {
+			seen[tag] = struct{}{}
+			free = append(free, tag)
+		}
+	})
+	if len(free) > maxFreeTags {
+		return true
+	}
+
+	for mask := 0; mask < 1<<uint(len(free)); mask++ {
+		on := make(map[string]bool, len(free))
+		for i, tag := range free {
+			on[tag] = mask&(1<<uint(i)) != 0
+		}
+		if expr.Eval(func(tag string) bool { return on[tag] }) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// collectTags calls fn for every tag of expr.
+func collectTags(expr constraint.Expr, fn func(tag string)) {
+	switch e := expr.(type) {
+	case *constraint.TagExpr:
+		fn(e.Tag)
+	case *constraint.NotExpr:
+		collectTags(e.X, fn)
+	case *constraint.AndExpr:
+		collectTags(e.X, fn)
+		collectTags(e.Y, fn)
+	case *constraint.OrExpr:
+		collectTags(e.X, fn)
+		collectTags(e.Y, fn)
+	}
+}
+
+// buildTarget is a platform project is built for.
+type buildTarget struct {
+	OS, Arch string
+}
+
+func (t buildTarget) String() string {
+	return t.OS + "/" + t.Arch
+}
+
+// parseBuildTarget parses target like linux/amd64.
+func parseBuildTarget(s string) (buildTarget, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) != 2 {
+		return buildTarget{}, fmt.Errorf("target (%s) should be in GOOS/GOARCH form", s)
+	}
+	t := buildTarget{OS: parts[0], Arch: parts[1]}
+	if _, ok := knownOS[t.OS]; !ok {
+		return buildTarget{}, fmt.Errorf("target (%s): unknown GOOS (%s)", s, t.OS)
+	}
+	if _, ok := knownArch[t.Arch]; !ok {
+		return buildTarget{}, fmt.Errorf("target (%s): unknown GOARCH (%s)", s, t.Arch)
+	}
+
+	return t, nil
+}
+
+// hasTag reports whether tag is set when building for target with extra tags.
+// Go release tags are assumed to be set, so no file needed by a newer toolchain is missed.
+func (t buildTarget) hasTag(tag string, tags []string) bool {
+	switch tag {
+	case t.OS, t.Arch, "gc":
+		return true
+	case "unix":
+		_, ok := unixOS[t.OS]
+		return ok
+	case "linux":
+		return t.OS == "android"
+	case "darwin":
+		return t.OS == "ios"
+	case "solaris":
+		return t.OS == "illumos"
+	}
+	if strings.HasPrefix(tag, "go1.") {
+		return true
+	}
+	for _, extra := range tags {
+		if tag == extra {
+			return true
+		}
+	}
+
+	return false
+}
+
+// buildsForTargets checks whether expr is satisfied by at least one of targets, excluded tags are always off.
+// cgo may be either enabled or disabled for any target.
+func buildsForTargets(expr constraint.Expr, targets []buildTarget, tags []string, excluded map[string]struct{}) bool {
+	for _, t := range targets {
+		for _, cgo := range []bool{true, false} {
+			ok := expr.Eval(func(tag string) bool {
+				if _, ok := excluded[tag]; ok {
+					return false
+				}
+				if tag == "cgo" {
+					return cgo
+				}
+				return t.hasTag(tag, tags)
+			})
+			if ok {
+				return true
+			}
+		}
+	}
+
+	return false
+}
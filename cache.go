@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// offline tells ven to use cached repositories only and never access network.
+var offline = envOffline()
+
+// syncedMirrors keeps paths of mirrors cloned or updated by this run, so every repo is fetched from remote once.
+var (
+	syncedMirrors   = make(map[string]struct{})
+	syncedMirrorsMu sync.Mutex
+)
+
+// envOffline checks whether offline mode is enabled by VEN_OFFLINE env.
+func envOffline() bool {
+	v, err := strconv.ParseBool(os.Getenv("VEN_OFFLINE"))
+	return err == nil && v
+}
+
+// notCachedError is returned in offline mode for packages missing in cache.
+type notCachedError struct {
+	pkg string
+}
+
+func (e notCachedError) Error() string {
+	return fmt.Sprintf("pkg (%s): not found in cache", e.pkg)
+}
+
+// groupNotCached joins errors about packages missing in cache into a single error listing them.
+func groupNotCached(errs importErrors) importErrors {
+	var (
+		missing []string
+		grouped = make(importErrors, 0, len(errs))
+	)
+	for _, err := range errs {
+		if e, ok := err.(notCachedError); ok {
+			missing = append(missing, e.pkg)
+			continue
+		}
+		grouped = append(grouped, err)
+	}
+	if len(missing) == 0 {
+		return errs
+	}
+	sort.Strings(missing)
+
+	return append(grouped, fmt.Errorf("offline mode, packages missing in cache: %s", strings.Join(missing, ", ")))
+}
+
+// cacheDir returns directory of shared repository mirrors.
+// It's $VEN_CACHE_DIR if set, otherwise $XDG_CACHE_HOME/ven/git (~/.cache/ven/git).
+func cacheDir() (string, error) {
+	if dir := os.Getenv("VEN_CACHE_DIR"); dir != "" {
+		return dir, nil
+	}
+
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot detect cache directory: %v", err)
+	}
+
+	return filepath.Join(dir, "ven", "git"), nil
+}
+
+// cachedRepoPath returns path of a pkg mirror in cache.
+func cachedRepoPath(root string) (string, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, filepath.FromSlash(root)), nil
+}
+
+// isMirror checks whether dir is a bare repository.
+func isMirror(dir string) bool {
+	if _, err := os.Stat(filepath.Join(dir, "HEAD")); err != nil {
+		return false
+	}
+	if _, err := os.Stat(filepath.Join(dir, "objects")); err != nil {
+		return false
+	}
+
+	return true
+}
+
+// syncCache makes sure cache has an up to date mirror of repo, returns mirror path.
+// Mirror is updated once per run, in offline mode existing mirror is returned as is.
+func syncCache(ctx context.Context, root, repo string) (string, error) {
+	path, err := cachedRepoPath(root)
+	if err != nil {
+		return "", err
+	}
+
+	if isMirror(path) {
+		if offline || mirrorSynced(path) {
+			return path, nil
+		}
+		if _, err := runGit(ctx, path, "remote", "update", "--prune"); err != nil {
+			return "", fmt.Errorf("cannot update cached repo: %v", err)
+		}
+		markMirrorSynced(path)
+		return path, nil
+	}
+	if offline {
+		return "", notCachedError{pkg: root}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("cannot create cache dir: %v", err)
+	}
+	// clone into a temporary dir first, so interrupted clone never looks like a mirror.
+	tmp, err := ioutil.TempDir(filepath.Dir(path), ".ven-mirror")
+	if err != nil {
+		return "", fmt.Errorf("cannot create cache dir: %v", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	if _, err := runGit(ctx, "", "clone", "--mirror", "--quiet", repo, tmp); err != nil {
+		return "", err
+	}
+	if err := os.RemoveAll(path); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return "", fmt.Errorf("cannot move repo to cache: %v", err)
+	}
+	markMirrorSynced(path)
+
+	return path, nil
+}
+
+func mirrorSynced(path string) bool {
+	syncedMirrorsMu.Lock()
+	defer syncedMirrorsMu.Unlock()
+
+	_, ok := syncedMirrors[path]
+	return ok
+}
+
+func markMirrorSynced(path string) {
+	syncedMirrorsMu.Lock()
+	defer syncedMirrorsMu.Unlock()
+
+	syncedMirrors[path] = struct{}{}
+}
+
+// scpURLRe matches scp-like repository urls, like git@github.com:user/repo.git.
+var scpURLRe = regexp.MustCompile(`^[\w.-]+@[\w.-]+:`)
+
+// isRepoURL checks whether source is a repository url or a local path rather than an import path.
+func isRepoURL(source string) bool {
+	return strings.Contains(source, "://") || strings.HasPrefix(source, "/") || strings.HasPrefix(source, ".") || scpURLRe.MatchString(source)
+}
+
+// urlCacheRoot converts repository url into a cache path, like https://github.com/user/repo.git into github.com/user/repo.
+func urlCacheRoot(url string) string {
+	s := url
+	if i := strings.Index(s, "://"); i != -1 {
+		s = s[i+3:]
+	} else if m := scpURLRe.FindString(s); m != "" {
+		s = strings.TrimSuffix(m, ":") + "/" + s[len(m):]
+	}
+	if i := strings.Index(s, "@"); i != -1 && i < strings.Index(s+"/", "/") {
+		s = s[i+1:]
+	}
+
+	return strings.TrimSuffix(strings.Trim(path.Clean("/"+s), "/"), ".git")
+}
+
+// getCachedRoot finds pkg root in manifest or the longest pkg path prefix which has a mirror in cache.
+func getCachedRoot(pkg string) (string, bool) {
+	if _, root, exists := manifest.PkgExists(pkg); exists {
+		if path, err := cachedRepoPath(root); err == nil && isMirror(path) {
+			return root, true
+		}
+	}
+
+	parts := strings.Split(pkg, "/")
+	for len(parts) != 0 {
+		root := strings.Join(parts, "/")
+		if path, err := cachedRepoPath(root); err == nil && isMirror(path) {
+			return root, true
+		}
+
+		parts = parts[:len(parts)-1]
+	}
+
+	return "", false
+}
+
+// listCachedRepos returns sorted import paths of cached mirrors.
+func listCachedRepos() ([]string, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return nil, err
+	}
+
+	var repos []string
+	err = filepath.Walk(dir, func(path string, f os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !f.IsDir() || path == dir {
+			return nil
+		}
+		if strings.HasPrefix(f.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if isMirror(path) {
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			repos = append(repos, filepath.ToSlash(rel))
+			return filepath.SkipDir
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(repos)
+
+	return repos, nil
+}
+
+// CacheList prints cached repositories.
+func CacheList(ctx context.Context) error {
+	repos, err := listCachedRepos()
+	if err != nil {
+		return err
+	}
+	for _, repo := range repos {
+		fmt.Println(repo)
+	}
+
+	return nil
+}
+
+// CacheClean removes specified repositories from cache, or the whole cache if none specified.
+func CacheClean(ctx context.Context, pkgs []string, verbose bool) error {
+	if len(pkgs) == 0 {
+		dir, err := cacheDir()
+		if err != nil {
+			return err
+		}
+		return os.RemoveAll(dir)
+	}
+
+	for _, pkg := range pkgs {
+		root, ok := getCachedRoot(pkg)
+		if !ok {
+			if verbose {
+				fmt.Printf("pkg (%s): not found in cache\n", pkg)
+			}
+			continue
+		}
+		path, err := cachedRepoPath(root)
+		if err != nil {
+			return err
+		}
+		if err := os.RemoveAll(path); err != nil {
+			return fmt.Errorf("pkg (%s): cannot remove from cache: %v", root, err)
+		}
+		if verbose {
+			fmt.Printf("removed %s\n", root)
+		}
+	}
+
+	return nil
+}
+
+// CacheVerify checks integrity of cached repositories.
+func CacheVerify(ctx context.Context, verbose bool) error {
+	repos, err := listCachedRepos()
+	if err != nil {
+		return err
+	}
+
+	var broken int
+	for _, repo := range repos {
+		if ctxCancelled(ctx) {
+			return ctx.Err()
+		}
+
+		path, err := cachedRepoPath(repo)
+		if err != nil {
+			return err
+		}
+		if _, err := runGit(ctx, path, "fsck", "--no-dangling", "--no-progress"); err != nil {
+			fmt.Printf("%s: %v\n", repo, err)
+			broken++
+			continue
+		}
+		if verbose {
+			fmt.Printf("%s: ok\n", repo)
+		}
+	}
+	if broken != 0 {
+		return fmt.Errorf("%d cached repo(s) are broken, remove them with `ven cache clean`", broken)
+	}
+
+	return nil
+}
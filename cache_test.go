@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_syncCacheOncePerRun(t *testing.T) {
+	dir := setupProject(t)
+	repo := filepath.Join(dir, "repos", "github.com/fx/a.git")
+	addTestRepo(t, dir, "github.com/fx/a", []testCommit{{Files: map[string]string{"a.go": "package a\n"}}})
+
+	ctx := context.Background()
+	path, err := syncCache(ctx, "github.com/fx/a", repo)
+	if err != nil {
+		t.Fatalf("syncCache() error: %v", err)
+	}
+	if !isMirror(path) {
+		t.Fatalf("%s is not a mirror", path)
+	}
+
+	// mirror synced by this run is not updated again, so unreachable remote doesn't matter.
+	if err := os.RemoveAll(repo); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := syncCache(ctx, "github.com/fx/a", repo); err != nil {
+		t.Errorf("syncCache() of synced mirror error: %v", err)
+	}
+
+	syncedMirrorsMu.Lock()
+	delete(syncedMirrors, path)
+	syncedMirrorsMu.Unlock()
+	if _, err := syncCache(ctx, "github.com/fx/a", repo); err == nil {
+		t.Error("syncCache() of mirror synced by another run did not update it")
+	}
+}
+
+func TestCache(t *testing.T) {
+	dir := setupProject(t)
+	ctx := context.Background()
+	for _, root := range []string{"github.com/fx/a", "github.com/fx/b", "gopkg.in/fx/c.v1"} {
+		addTestRepo(t, dir, root, []testCommit{{Files: map[string]string{"x.go": "package x\n"}}})
+		if _, err := syncCache(ctx, root, filepath.Join(dir, "repos", root+".git")); err != nil {
+			t.Fatalf("syncCache(%s) error: %v", root, err)
+		}
+	}
+
+	out := captureStdout(t, func() {
+		if err := CacheList(ctx); err != nil {
+			t.Errorf("CacheList() error: %v", err)
+		}
+	})
+	if want := "github.com/fx/a\ngithub.com/fx/b\ngopkg.in/fx/c.v1\n"; out != want {
+		t.Errorf("CacheList() = %q, want %q", out, want)
+	}
+
+	if err := CacheVerify(ctx, false); err != nil {
+		t.Errorf("CacheVerify() error: %v", err)
+	}
+
+	// a subpackage import path removes its repo.
+	if err := CacheClean(ctx, []string{"github.com/fx/b/sub"}, false); err != nil {
+		t.Fatalf("CacheClean() error: %v", err)
+	}
+	repos, err := listCachedRepos()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(repos, " "); got != "github.com/fx/a gopkg.in/fx/c.v1" {
+		t.Errorf("cached repos after clean = %s", got)
+	}
+
+	path, err := cachedRepoPath("github.com/fx/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	objects, err := filepath.Glob(filepath.Join(path, "objects", "[0-9a-f][0-9a-f]", "*"))
+	if err != nil || len(objects) == 0 {
+		t.Fatalf("no objects in mirror: %v", err)
+	}
+	for _, obj := range objects {
+		if err := os.Remove(obj); err != nil {
+			t.Fatal(err)
+		}
+	}
+	captureStdout(t, func() {
+		if err := CacheVerify(ctx, false); err == nil {
+			t.Error("CacheVerify() of broken repo succeeded")
+		}
+	})
+
+	if err := CacheClean(ctx, nil, false); err != nil {
+		t.Fatalf("CacheClean() error: %v", err)
+	}
+	if repos, err := listCachedRepos(); err != nil || len(repos) != 0 {
+		t.Errorf("cached repos after cleaning everything = %v, %v", repos, err)
+	}
+}
+
+func TestOffline(t *testing.T) {
+	dir := setupProject(t)
+	addTestRepo(t, dir, "github.com/fx/g", []testCommit{
+		{Files: map[string]string{"g.go": "package g\n"}, Tag: "v1.0.0"},
+		{Files: map[string]string{"g.go": "package g\n\nconst V = 1\n"}, Tag: "v1.1.0"},
+	})
+
+	manifest.Constraints["github.com/fx/g"] = "v1.0.0"
+	fetchTestProject(t, "github.com/fx/g")
+	delete(manifest.Constraints, "github.com/fx/g")
+	if err := saveManifest(); err != nil {
+		t.Fatal(err)
+	}
+
+	offline = true
+	var err error
+	for _, update := range []bool{false, true} {
+		resetImportState()
+		if manifest, err = parseManifest(); err != nil {
+			t.Fatal(err)
+		}
+		if err := Fetch(context.Background(), "example.com/proj", update, 2, false); err != nil {
+			t.Fatalf("offline Fetch(update %v) error: %v", update, err)
+		}
+	}
+	if g := manifest.Packages["github.com/fx/g"]; g.Version != "v1.1.0" {
+		t.Errorf("offline update of github.com/fx/g version = %s, want cached v1.1.0", g.Version)
+	}
+
+	if err := reinstallProject(t, 2); err != nil {
+		t.Errorf("offline Install() error: %v", err)
+	}
+
+	_, err = Get(context.Background(), []string{"github.com/fx/n"}, false, false, false, false)
+	if err == nil || !strings.Contains(err.Error(), "packages missing in cache: github.com/fx/n") {
+		t.Errorf("offline Get() of not cached pkg error = %v, want github.com/fx/n missing in cache", err)
+	}
+}
+
+func TestOfflineNonGit(t *testing.T) {
+	dir := setupProject(t)
+	addTestRepo(t, dir, "github.com/fx/g", []testCommit{
+		{Files: map[string]string{"g.go": "package g\n"}, Tag: "v1.0.0"},
+	})
+	fetchTestProject(t, "github.com/fx/g")
+
+	// h is a mercurial repo, which is never cached, so offline only its vendored version can be used.
+	writeFile(t, "vendor/github.com/fx/h/h.go", "package h\n")
+	hHash, err := pkgHash("github.com/fx/h", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := Package{
+		Version:     "v1.0.0",
+		CommitHash:  "0123456789012345678901234567890123456789",
+		Hash:        hHash,
+		VCS:         "hg",
+		Deps:        make(map[string]struct{}),
+		Subpackages: make(map[string]struct{}),
+	}
+	manifest.Packages["github.com/fx/h"] = h
+	if err := saveManifest(); err != nil {
+		t.Fatal(err)
+	}
+	writeMain(t, "github.com/fx/g", "github.com/fx/h")
+
+	offline = true
+	for _, update := range []bool{false, true} {
+		resetImportState()
+		if manifest, err = parseManifest(); err != nil {
+			t.Fatal(err)
+		}
+		if err := Fetch(context.Background(), "example.com/proj", update, 2, false); err != nil {
+			t.Fatalf("offline Fetch(update %v) error: %v", update, err)
+		}
+		if got := manifest.Packages["github.com/fx/h"]; got.CommitHash != h.CommitHash || got.Hash != h.Hash {
+			t.Errorf("offline Fetch(update %v) changed vendored hg pkg: %s", update, got)
+		}
+		if _, err := os.Stat("vendor/github.com/fx/h/h.go"); err != nil {
+			t.Errorf("offline Fetch(update %v) removed vendored hg pkg: %v", update, err)
+		}
+	}
+
+	resetImportState()
+	if manifest, err = parseManifest(); err != nil {
+		t.Fatal(err)
+	}
+	_, err = Get(context.Background(), []string{"github.com/fx/h@v2.0.0"}, true, false, false, false)
+	if err == nil || !strings.Contains(err.Error(), "offline mode fetches only git repos") {
+		t.Errorf("offline Get() of not vendored hg version error = %v, want offline mode fetches only git repos", err)
+	}
+
+	writeFile(t, "vendor/github.com/fx/h/h.go", "package h\n\nconst Modified = true\n")
+	resetImportState()
+	if manifest, err = parseManifest(); err != nil {
+		t.Fatal(err)
+	}
+	err = Fetch(context.Background(), "example.com/proj", true, 2, false)
+	if err == nil || !strings.Contains(err.Error(), "vendored files were modified") {
+		t.Errorf("offline Fetch() of modified hg pkg error = %v, want vendored files were modified", err)
+	}
+
+	if err := os.RemoveAll("vendor"); err != nil {
+		t.Fatal(err)
+	}
+	resetImportState()
+	if manifest, err = parseManifest(); err != nil {
+		t.Fatal(err)
+	}
+	err = Install(context.Background(), 2, false)
+	if err == nil || !strings.Contains(err.Error(), "pkg (github.com/fx/h)") || strings.Contains(err.Error(), "github.com/fx/g") {
+		t.Errorf("offline Install() error = %v, want only not vendored github.com/fx/h", err)
+	}
+}
@@ -0,0 +1,442 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// conflict policies decide which version to import when dependencies request different versions of a pkg.
+const (
+	// conflictHighest picks the highest semver tag, or the newest commit.
+	conflictHighest = "highest"
+	// conflictManifest keeps the version pinned in the manifest, a conflict on a pkg missing in the manifest is an error.
+	conflictManifest = "manifest"
+	// conflictFail fails on any conflict.
+	conflictFail = "fail"
+)
+
+// versionRequest describes pkg version requested by a dependency lock file.
+type versionRequest struct {
+	// Version is a revision to checkout: a tag or a commit.
+	Version string
+	// Tag is a human-readable version of a commit.
+	Tag string
+	// Constraint is a semver range requested instead of a version, it's never compared with revisions.
+	Constraint string
+	Source     string
+	VCS        string
+	Requester  string
+	Tool       string
+	// Subpackages lists pkg subpackages used by requester.
+	Subpackages []string
+	// Project tells that version is pinned by lock file of the project itself, it is set only by migrate.
+	Project bool
+}
+
+func (r versionRequest) String() string {
+	if r.Version == "" {
+		return r.Constraint
+	}
+
+	return r.Version
+}
+
+// versionConflict describes pkg requested with different versions.
+type versionConflict struct {
+	Name     string
+	Requests []versionRequest
+}
+
+// addCachedConstraint records pkg version or semver range requested by a requester lock file.
+func addCachedConstraint(pkg string, req versionRequest) {
+	if req.Version == "" && req.Constraint == "" {
+		return
+	}
+
+	importMu.Lock()
+	defer importMu.Unlock()
+
+	reqs := cachedConstraints[pkg]
+	for i, r := range reqs {
+		if r.Version == req.Version && r.Constraint == req.Constraint && r.Source == req.Source && r.Requester == req.Requester && r.Tool == req.Tool && r.Project == req.Project {
+			reqs[i].Subpackages = append(reqs[i].Subpackages, req.Subpackages...)
+			return
+		}
+	}
+	cachedConstraints[pkg] = append(reqs, req)
+}
+
+// getCachedConstraints returns versions requested for pkg, sorted by requester.
+func getCachedConstraints(pkg string) []versionRequest {
+	importMu.Lock()
+	reqs := make([]versionRequest, 0, len(cachedConstraints[pkg]))
+	for _, r := range cachedConstraints[pkg] {
+		r.Subpackages = append([]string(nil), r.Subpackages...)
+		reqs = append(reqs, r)
+	}
+	importMu.Unlock()
+
+	sort.Slice(reqs, func(i, j int) bool {
+		if reqs[i].Requester != reqs[j].Requester {
+			return reqs[i].Requester < reqs[j].Requester
+		}
+		return reqs[i].String() < reqs[j].String()
+	})
+
+	return reqs
+}
+
+// requestedVersions returns sorted distinct versions of requests, semver ranges are skipped.
+func requestedVersions(reqs []versionRequest) []string {
+	seen := make(map[string]struct{})
+	var versions []string
+	for _, r := range reqs {
+		if r.Version == "" {
+			continue
+		}
+		if _, ok := seen[r.Version]; !ok {
+			seen[r.Version] = struct{}{}
+			versions = append(versions, r.Version)
+		}
+	}
+	sort.Strings(versions)
+
+	return versions
+}
+
+// resolveCachedConstraint picks version of pkg requested by dependencies according to manifest conflict policy.
+// Candidates are returned if the newest of them can be picked only after the pkg repo is fetched,
+// in this case request has only source and subpackages common for all requests.
+// If usePins is set, version pinned by the project lock file wins over dependencies.
+// Semver ranges are used only if no exact version is requested: all of them are joined into one range,
+// which is resolved to the highest satisfying tag on checkout.
+func resolveCachedConstraint(pkg string, usePins bool) (req versionRequest, candidates []string, err error) {
+	if usePins {
+		if pin, ok := projectPin(pkg); ok {
+			return pin, nil, nil
+		}
+	}
+
+	var reqs, ranges []versionRequest
+	for _, r := range getCachedConstraints(pkg) {
+		if r.Version == "" {
+			ranges = append(ranges, r)
+		} else {
+			reqs = append(reqs, r)
+		}
+	}
+	versions := requestedVersions(reqs)
+	switch len(versions) {
+	case 0:
+		if len(ranges) == 0 {
+			return versionRequest{}, nil, nil
+		}
+		req := mergeRequests(ranges)
+		req.Version = joinRanges(ranges)
+		return req, nil, nil
+	case 1:
+		return mergeRequests(reqs), nil, nil
+	}
+
+	conflict := versionConflict{Name: pkg, Requests: reqs}
+	switch manifest.ConflictPolicy {
+	case conflictFail:
+		return versionRequest{}, nil, fmt.Errorf("pkg (%s): dependencies require different versions:\n%s", pkg, formatConflicts([]versionConflict{conflict}))
+	case conflictManifest:
+		if info, _, exists := manifest.PkgExists(pkg); exists && info.CommitHash != "" {
+			return versionRequest{Version: info.CommitHash, Source: info.Source, VCS: info.VCS}, nil, nil
+		}
+		return versionRequest{}, nil, fmt.Errorf("pkg (%s): dependencies require different versions and manifest has no pin:\n%s", pkg, formatConflicts([]versionConflict{conflict}))
+	default:
+		if tag := latestTag(versions, nil); tag != "" && len(versions) == countSemver(versions) {
+			var selected []versionRequest
+			for _, r := range reqs {
+				if r.Version == tag {
+					selected = append(selected, r)
+				}
+			}
+			return mergeRequests(selected), nil, nil
+		}
+		req := mergeRequests(reqs)
+		req.Version, req.Tag = "", ""
+		return req, versions, nil
+	}
+}
+
+// joinRanges joins distinct semver ranges of requests, so a version has to satisfy all of them.
+func joinRanges(reqs []versionRequest) string {
+	seen := make(map[string]struct{})
+	var ranges []string
+	for _, r := range reqs {
+		if _, ok := seen[r.Constraint]; !ok {
+			seen[r.Constraint] = struct{}{}
+			ranges = append(ranges, r.Constraint)
+		}
+	}
+	sort.Strings(ranges)
+
+	return strings.Join(ranges, " ")
+}
+
+// mergeRequests merges requests into one: subpackages are joined,
+// other fields are kept only if all requests agree on them.
+func mergeRequests(reqs []versionRequest) versionRequest {
+	if len(reqs) == 0 {
+		return versionRequest{}
+	}
+
+	merged := reqs[0]
+	merged.Subpackages = nil
+	for _, r := range reqs {
+		if r.Version != merged.Version {
+			merged.Version = ""
+		}
+		if r.Tag != merged.Tag {
+			merged.Tag = ""
+		}
+		if r.Constraint != merged.Constraint {
+			merged.Constraint = ""
+		}
+		if r.Source != merged.Source || r.VCS != merged.VCS {
+			merged.Source, merged.VCS = "", ""
+		}
+		merged.Subpackages = append(merged.Subpackages, r.Subpackages...)
+	}
+
+	return merged
+}
+
+// projectPin returns version of pkg pinned by the project lock file.
+func projectPin(pkg string) (versionRequest, bool) {
+	var pins []versionRequest
+	for _, r := range getCachedConstraints(pkg) {
+		if r.Project {
+			pins = append(pins, r)
+		}
+	}
+	if len(pins) == 0 {
+		return versionRequest{}, false
+	}
+
+	pin := mergeRequests(pins)
+	return pin, pin.Version != ""
+}
+
+// requestForCommit finds request of pkg version matching commit.
+func requestForCommit(pkg, commit string) (versionRequest, bool) {
+	for _, r := range getCachedConstraints(pkg) {
+		if r.Version != "" && strings.HasPrefix(commit, r.Version) {
+			return r, true
+		}
+	}
+
+	return versionRequest{}, false
+}
+
+func countSemver(versions []string) int {
+	var n int
+	for _, v := range versions {
+		if _, ok := parseSemver(v); ok {
+			n++
+		}
+	}
+
+	return n
+}
+
+// resolvedPkg describes pkg imported with version picked from versions requested by dependencies.
+type resolvedPkg struct {
+	// task is the last task pkg was imported with.
+	task importTask
+	// picks lists versions, or candidates joined with spaces, pkg was imported with.
+	picks map[string]struct{}
+}
+
+// resolutionPick returns a key of version or candidates picked by resolveCachedConstraint.
+func resolutionPick(version string, candidates []string) string {
+	if len(candidates) != 0 {
+		return strings.Join(candidates, " ")
+	}
+
+	return version
+}
+
+// addResolvedPkg records version of pkg resolved by plan.
+func addResolvedPkg(pkg string, plan *importPlan) {
+	r, ok := resolvedPkgs[pkg]
+	if !ok {
+		r.picks = make(map[string]struct{})
+	}
+	r.task = plan.task
+	r.picks[resolutionPick(plan.version, plan.candidates)] = struct{}{}
+	resolvedPkgs[pkg] = r
+}
+
+// reresolvePkgs resolves versions of resolvedPkgs again, since requests found after a pkg was imported may change the pick,
+// like a lock file of a pkg imported on the same level. Pkgs with a new pick are removed from manifest and vendor
+// together with versions their lock files requested, returned tasks import them again.
+// A pick pkg was already imported with is never retried, so resolution always ends.
+func reresolvePkgs(verbose bool) ([]importTask, error) {
+	names := make([]string, 0, len(resolvedPkgs))
+	for name := range resolvedPkgs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var tasks []importTask
+	for _, name := range names {
+		r := resolvedPkgs[name]
+		info, exists := manifest.Packages[name]
+		if !exists {
+			continue
+		}
+		req, candidates, err := resolveCachedConstraint(name, !r.task.opts.Update)
+		if err != nil {
+			return nil, err
+		}
+		pick := resolutionPick(req.Version, candidates)
+		if _, ok := r.picks[pick]; ok {
+			continue
+		}
+		if verbose {
+			fmt.Printf("pkg (%s): dependencies requested other versions, importing (%s) instead of (%s)\n", name, pick, info)
+		}
+
+		if err := os.RemoveAll(fmt.Sprintf("%s/%s", manifest.VendorPath, name)); err != nil {
+			return nil, fmt.Errorf("pkg (%s): fail remove existing pkg: %v", name, err)
+		}
+		delete(manifest.Packages, name)
+		delete(cachedPkgs, name)
+		dropRequestsOf(name)
+
+		task := importTask{pkg: name, opts: r.task.opts}
+		task.opts.Subpackages = append([]string(nil), task.opts.Subpackages...)
+		for subpkg := range info.Subpackages {
+			task.opts.Subpackages = append(task.opts.Subpackages, subpkg)
+		}
+		sort.Strings(task.opts.Subpackages)
+		tasks = append(tasks, task)
+	}
+
+	return tasks, nil
+}
+
+// dropRequestsOf removes versions requested by lock file of requester.
+func dropRequestsOf(requester string) {
+	importMu.Lock()
+	defer importMu.Unlock()
+
+	for pkg, reqs := range cachedConstraints {
+		var kept []versionRequest
+		for _, r := range reqs {
+			if r.Requester != requester {
+				kept = append(kept, r)
+			}
+		}
+		if len(kept) == 0 {
+			delete(cachedConstraints, pkg)
+			continue
+		}
+		cachedConstraints[pkg] = kept
+	}
+}
+
+// versionConflicts lists pkgs requested with different versions, sorted by name.
+func versionConflicts() []versionConflict {
+	importMu.Lock()
+	names := make([]string, 0, len(cachedConstraints))
+	for name := range cachedConstraints {
+		names = append(names, name)
+	}
+	importMu.Unlock()
+	sort.Strings(names)
+
+	var conflicts []versionConflict
+	for _, name := range names {
+		reqs := getCachedConstraints(name)
+		// version pinned by the project is not a conflict.
+		if _, ok := projectPin(name); ok {
+			continue
+		}
+		if len(requestedVersions(reqs)) > 1 {
+			conflicts = append(conflicts, versionConflict{Name: name, Requests: reqs})
+		}
+	}
+
+	return conflicts
+}
+
+// formatConflicts formats conflicts as a table, versions picked into manifest are marked as selected.
+func formatConflicts(conflicts []versionConflict) string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PACKAGE\tVERSION\tREQUESTED BY\tTOOL\t")
+	for _, c := range conflicts {
+		info, _, exists := manifest.PkgExists(c.Name)
+		for i, r := range c.Requests {
+			name := c.Name
+			if i != 0 {
+				name = ""
+			}
+			version := r.String()
+			if exists && versionMatches(info, r.Version) {
+				version += " (selected)"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t\n", name, version, r.Requester, r.Tool)
+		}
+	}
+	w.Flush()
+
+	return b.String()
+}
+
+// versionMatches checks whether requested version is the one pkg has, version can be a tag or a full or short commit.
+func versionMatches(info Package, version string) bool {
+	if version == "" {
+		return false
+	}
+
+	return version == info.Version || strings.HasPrefix(info.CommitHash, version)
+}
+
+// reportConflicts prints version conflicts found during import, with fail policy conflicts are returned as an error.
+// Conflicts of pkgs whose version wasn't picked by the policy, since it's set by a constraint, requested explicitly
+// or kept from the manifest, are listed separately.
+func reportConflicts() error {
+	conflicts := versionConflicts()
+	if len(conflicts) == 0 {
+		return nil
+	}
+	if manifest.ConflictPolicy == conflictFail {
+		return fmt.Errorf("dependencies require different versions:\n%s", formatConflicts(conflicts))
+	}
+
+	var resolved, kept []versionConflict
+	for _, c := range conflicts {
+		if _, ok := resolvedPkgs[c.Name]; ok {
+			resolved = append(resolved, c)
+		} else {
+			kept = append(kept, c)
+		}
+	}
+	if len(resolved) != 0 {
+		fmt.Printf("dependencies require different versions, resolved with (%s) policy:\n%s", conflictPolicy(), formatConflicts(resolved))
+	}
+	if len(kept) != 0 {
+		fmt.Printf("dependencies require different versions, kept versions set by constraints, requested explicitly or pinned in manifest:\n%s", formatConflicts(kept))
+	}
+
+	return nil
+}
+
+// conflictPolicy returns manifest conflict policy, highest by default.
+func conflictPolicy() string {
+	if manifest.ConflictPolicy == "" {
+		return conflictHighest
+	}
+
+	return manifest.ConflictPolicy
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// pkgReferencedFiles returns paths of files go files of a package vendored in dir need besides go sources:
+// files matched by //go:embed patterns, headers included by cgo preamble and files or dirs set in #cgo flags with ${SRCDIR}.
+// Go files of excluded dirs are not scanned, files outside of dir are never returned.
+func pkgReferencedFiles(dir string) (map[string]struct{}, error) {
+	refs := make(map[string]struct{})
+	root := filepath.Clean(dir)
+	add := func(path string) {
+		path = filepath.Clean(path)
+		if path == root || !strings.HasPrefix(path, root+string(filepath.Separator)) {
+			return
+		}
+		refs[path] = struct{}{}
+	}
+
+	err := filepath.Walk(dir, func(path string, f os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if f.IsDir() {
+			if path != dir && dirIsExcluded(path) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".go") || (strings.HasSuffix(path, "_test.go") && !includePkgTests()) {
+			return nil
+		}
+
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("fail read file %s: %v", path, err)
+		}
+		embedded, err := embedFiles(path, src)
+		if err != nil {
+			return err
+		}
+		for _, file := range embedded {
+			add(file)
+		}
+		for _, ref := range cgoFiles(path, src) {
+			files, err := expandRef(ref, false)
+			if err != nil {
+				return err
+			}
+			for _, file := range files {
+				add(file)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return refs, nil
+}
+
+// embedFiles returns files matched by //go:embed directives of go file at path.
+// As in go toolchain, files starting with . or _ inside matched dirs are skipped unless pattern has all: prefix.
+func embedFiles(path string, src []byte) ([]string, error) {
+	var files []string
+	scanner := bufio.NewScanner(bytes.NewReader(src))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "//go:embed ") {
+			continue
+		}
+		patterns, err := parseEmbedPatterns(strings.TrimPrefix(line, "//go:embed "))
+		if err != nil {
+			return nil, fmt.Errorf("file %s: invalid go:embed: %v", path, err)
+		}
+
+		for _, pattern := range patterns {
+			all := strings.HasPrefix(pattern, "all:")
+			pattern = strings.TrimPrefix(pattern, "all:")
+			matches, err := filepath.Glob(filepath.Join(filepath.Dir(path), filepath.FromSlash(pattern)))
+			if err != nil {
+				return nil, fmt.Errorf("file %s: invalid go:embed pattern (%s): %v", path, pattern, err)
+			}
+			for _, match := range matches {
+				matched, err := expandRef(match, !all)
+				if err != nil {
+					return nil, err
+				}
+				files = append(files, matched...)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("fail read file %s: %v", path, err)
+	}
+
+	return files, nil
+}
+
+// parseEmbedPatterns splits go:embed arguments, which are space separated and may be quoted.
+func parseEmbedPatterns(args string) ([]string, error) {
+	var patterns []string
+	for {
+		args = strings.TrimSpace(args)
+		if args == "" {
+			return patterns, nil
+		}
+
+		switch args[0] {
+		case '"':
+			end := 1
+			for end < len(args) && args[end] != '"' {
+				if args[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(args) {
+				return nil, fmt.Errorf("unterminated quote in %s", args)
+			}
+			pattern, err := strconv.Unquote(args[:end+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid quoted pattern %s: %v", args[:end+1], err)
+			}
+			patterns = append(patterns, pattern)
+			args = args[end+1:]
+		case '`':
+			end := strings.IndexByte(args[1:], '`')
+			if end == -1 {
+				return nil, fmt.Errorf("unterminated quote in %s", args)
+			}
+			patterns = append(patterns, args[1:end+1])
+			args = args[end+2:]
+		default:
+			end := strings.IndexAny(args, " \t")
+			if end == -1 {
+				end = len(args)
+			}
+			patterns = append(patterns, args[:end])
+			args = args[end:]
+		}
+	}
+}
+
+// cgoFiles returns paths referenced by cgo preamble of go file at path:
+// local #include headers and paths with ${SRCDIR} in #cgo flags, like -I${SRCDIR}/include.
+func cgoFiles(path string, src []byte) []string {
+	f, err := parser.ParseFile(token.NewFileSet(), path, src, parser.ImportsOnly|parser.ParseComments)
+	if err != nil {
+		return nil
+	}
+
+	var preambles []*ast.CommentGroup
+	for _, decl := range f.Decls {
+		d, ok := decl.(*ast.GenDecl)
+		if !ok || d.Tok != token.IMPORT {
+			continue
+		}
+		for _, spec := range d.Specs {
+			s := spec.(*ast.ImportSpec)
+			if s.Path.Value != `"C"` {
+				continue
+			}
+			if s.Doc != nil {
+				preambles = append(preambles, s.Doc)
+			} else if d.Doc != nil && !d.Lparen.IsValid() {
+				preambles = append(preambles, d.Doc)
+			}
+		}
+	}
+
+	var (
+		refs   []string
+		srcDir = filepath.Dir(path)
+	)
+	for _, preamble := range preambles {
+		for _, line := range strings.Split(preamble.Text(), "\n") {
+			line = strings.TrimSpace(line)
+			switch {
+			case strings.HasPrefix(line, "#include") || strings.HasPrefix(line, "#import"):
+				// only "local.h" includes are looked up in the package, <system.h> ones are not.
+				arg := strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(line, "#include"), "#import"))
+				if parts := strings.Split(arg, `"`); len(parts) >= 3 && parts[0] == "" {
+					refs = append(refs, filepath.Join(srcDir, filepath.FromSlash(parts[1])))
+				}
+			case strings.HasPrefix(line, "#cgo ") && strings.Contains(line, "${SRCDIR}"):
+				i := strings.Index(line, ":")
+				if i == -1 {
+					continue
+				}
+				for _, flag := range strings.Fields(line[i+1:]) {
+					if !strings.Contains(flag, "${SRCDIR}") {
+						continue
+					}
+					flag = strings.Replace(flag, "${SRCDIR}", srcDir, -1)
+					if i := strings.Index(flag, srcDir); i > 0 {
+						// strip flag name, like -I or -Wl,-rpath,
+						flag = flag[i:]
+					}
+					refs = append(refs, flag)
+				}
+			}
+		}
+	}
+
+	return refs
+}
+
+// expandRef returns ref if it's a file, or files in ref dir recursively, skipping hidden files in dirs if skipHidden is set.
+// Missing ref is ignored.
+func expandRef(ref string, skipHidden bool) ([]string, error) {
+	f, err := os.Stat(ref)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if !f.IsDir() {
+		return []string{ref}, nil
+	}
+
+	var files []string
+	err = filepath.Walk(ref, func(path string, f os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != ref && skipHidden && (strings.HasPrefix(f.Name(), ".") || strings.HasPrefix(f.Name(), "_")) {
+			if f.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if f.Mode().IsRegular() {
+			files = append(files, path)
+		}
+		return nil
+	})
+
+	return files, err
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// goModule describes manifest pkg exported as a go module.
+type goModule struct {
+	Path    string
+	Version string
+	// GoMod is a go.mod file of the module at its commit.
+	GoMod []byte
+	// Sum is h1 hash of module files at its commit, set only if go.sum is exported.
+	Sum string
+	// Dir is a replacement dir for local packages.
+	Dir string
+}
+
+// ExportGoMod generates go.mod from manifest, and go.sum if sum is set. Go hashes full module trees, not filtered
+// vendor, so go.sum is computed from commits of packages in cache repositories.
+// Existing files are overwritten only if force is set.
+func ExportGoMod(ctx context.Context, sum, force, verbose bool) error {
+	files := []string{"go.mod"}
+	if sum {
+		files = append(files, "go.sum")
+	}
+	if !force {
+		for _, file := range files {
+			if _, err := os.Stat(file); err == nil {
+				return fmt.Errorf("%s already exists, use --force to overwrite it", file)
+			}
+		}
+	}
+
+	modPath, err := currentPkg()
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(manifest.Packages))
+	for name := range manifest.Packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var (
+		modules []goModule
+		errs    importErrors
+	)
+	for _, name := range names {
+		if ctxCancelled(ctx) {
+			return ctx.Err()
+		}
+		if _, local := manifest.IsLocalPkg(name); local {
+			dir, ok := gopathPkgDir(name)
+			if !ok {
+				errs = append(errs, fmt.Errorf("pkg (%s): local pkg is not found in GOPATH", name))
+				continue
+			}
+			modules = append(modules, goModule{Path: name, Version: "v0.0.0", Dir: dir})
+			continue
+		}
+
+		mod, err := getGoModule(ctx, name, manifest.Packages[name], sum, verbose)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		modules = append(modules, mod)
+	}
+	if err := errs.errOrNil(); err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile("go.mod", formatGoMod(modPath, goVersion(ctx), modules), 0644); err != nil {
+		return fmt.Errorf("cannot write go.mod: %v", err)
+	}
+	if !sum {
+		return nil
+	}
+
+	if err := ioutil.WriteFile("go.sum", formatGoSum(modules), 0644); err != nil {
+		return fmt.Errorf("cannot write go.sum: %v", err)
+	}
+
+	return nil
+}
+
+// getGoModule converts pkg version and commit into a module version: a tag if the commit is tagged,
+// otherwise a pseudo-version built from the latest tag before the commit and the commit timestamp.
+// Module files are hashed for go.sum if sum is set.
+func getGoModule(ctx context.Context, pkg string, info Package, sum, verbose bool) (goModule, error) {
+	if vcsName(info.VCS) != "git" {
+		return goModule{}, fmt.Errorf("pkg (%s): export supports only git repos, pkg uses %s", pkg, info.VCS)
+	}
+
+	dir, err := getRepoDir(ctx, pkg, verbose)
+	if err != nil {
+		return goModule{}, err
+	}
+	mod := goModule{Path: pkg}
+
+	out, err := runGit(ctx, dir, "tag", "--points-at", info.CommitHash)
+	if err != nil {
+		return goModule{}, fmt.Errorf("pkg (%s): cannot list tags of commit %s: %v", pkg, info.CommitHash, err)
+	}
+	if tag := latestTag(strings.Fields(out), isModuleTag); tag != "" {
+		mod.Version = incompatibleVersion(tag)
+	} else {
+		out, err := runGit(ctx, dir, "tag", "--merged", info.CommitHash)
+		if err != nil {
+			return goModule{}, fmt.Errorf("pkg (%s): cannot list tags before commit %s: %v", pkg, info.CommitHash, err)
+		}
+		commitTime, err := gitDriver{}.RevisionTime(ctx, dir, info.CommitHash)
+		if err != nil {
+			return goModule{}, fmt.Errorf("pkg (%s): cannot get time of commit %s: %v", pkg, info.CommitHash, err)
+		}
+
+		base := latestTag(strings.Fields(out), isModuleTag)
+		mod.Version = pseudoVersion(base, commitTime.UTC().Format("20060102150405"), info.CommitHash)
+	}
+
+	if data, err := runGit(ctx, dir, "show", info.CommitHash+":go.mod"); err == nil {
+		mod.GoMod = []byte(data)
+	} else {
+		// go synthesizes the same file for modules without go.mod.
+		mod.GoMod = []byte(fmt.Sprintf("module %s\n", pkg))
+	}
+	// a module with major version suffix, like its own go.mod path github.com/a/b/v2 or gopkg.in/yaml.v2,
+	// is compatible with its v2+ tags.
+	if modPath := goModPath(mod.GoMod); modPath != pkg && strings.HasPrefix(modPath, pkg+"/") && hasMajorSuffix(modPath) {
+		mod.Path = modPath
+	}
+	if hasMajorSuffix(mod.Path) {
+		mod.Version = strings.TrimSuffix(mod.Version, "+incompatible")
+	}
+	if sum {
+		if mod.Sum, err = hashModule(ctx, dir, info.CommitHash, fmt.Sprintf("%s@%s/", pkg, mod.Version)); err != nil {
+			return goModule{}, fmt.Errorf("pkg (%s): cannot compute hash: %v", pkg, err)
+		}
+	}
+
+	return mod, nil
+}
+
+// hashModule computes go.sum h1 hash of module at commit of repo in dir. The same files go puts into module zip
+// are hashed: files of nested modules, vendored packages and vcs dirs are skipped.
+func hashModule(ctx context.Context, dir, commit, prefix string) (string, error) {
+	out, err := runGit(ctx, dir, "archive", "--format=tar", commit)
+	if err != nil {
+		return "", fmt.Errorf("cannot archive commit %s: %v", commit, err)
+	}
+
+	hashes := make(map[string][]byte)
+	r := tar.NewReader(strings.NewReader(out))
+	for {
+		hdr, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("cannot read archive of commit %s: %v", commit, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		h := sha256.New()
+		if _, err := io.Copy(h, r); err != nil {
+			return "", fmt.Errorf("cannot read archive of commit %s: %v", commit, err)
+		}
+		hashes[hdr.Name] = h.Sum(nil)
+	}
+
+	var modDirs []string
+	for name := range hashes {
+		if path.Base(name) == "go.mod" && name != "go.mod" {
+			modDirs = append(modDirs, path.Dir(name)+"/")
+		}
+	}
+	for name := range hashes {
+		if !isModuleFile(name, modDirs) {
+			delete(hashes, name)
+		}
+	}
+
+	return h1Sum(prefix, hashes)
+}
+
+// isModuleFile checks whether file of a repo tree is put into module zip, as in golang.org/x/mod/zip:
+// files in dirs of nested modules, in vcs dirs and in vendored packages are not.
+func isModuleFile(name string, modDirs []string) bool {
+	for _, dir := range modDirs {
+		if strings.HasPrefix(name, dir) {
+			return false
+		}
+	}
+	for _, elem := range strings.Split(path.Dir(name), "/") {
+		switch elem {
+		case ".bzr", ".git", ".hg", ".svn":
+			return false
+		}
+	}
+
+	// a file in vendor dir itself is in package "vendor", it's not vendored.
+	i := strings.Index("/"+name, "/vendor/")
+	if i == -1 {
+		return true
+	}
+
+	return !strings.Contains(name[i+len("vendor/"):], "/")
+}
+
+// isModuleTag checks whether tag is a canonical semver, like v1.2.3, that go modules accept.
+func isModuleTag(tag string) bool {
+	_, parts, ok := parsePartialSemver(tag)
+	return ok && parts == 3 && strings.HasPrefix(tag, "v") && !strings.Contains(tag, "+")
+}
+
+// pseudoVersion builds go pseudo-version of a commit made after base tag.
+func pseudoVersion(base, timestamp, commit string) string {
+	if len(commit) > 12 {
+		commit = commit[:12]
+	}
+
+	sv, ok := parseSemver(base)
+	switch {
+	case !ok:
+		return fmt.Sprintf("v0.0.0-%s-%s", timestamp, commit)
+	case sv.Pre != "":
+		return incompatibleVersion(fmt.Sprintf("%s.0.%s-%s", base, timestamp, commit))
+	default:
+		return incompatibleVersion(fmt.Sprintf("v%d.%d.%d-0.%s-%s", sv.Major, sv.Minor, sv.Patch+1, timestamp, commit))
+	}
+}
+
+// incompatibleVersion marks v2+ versions as +incompatible, since ven import paths have no major version suffix.
+func incompatibleVersion(version string) string {
+	if sv, ok := parseSemver(version); ok && sv.Major >= 2 {
+		return version + "+incompatible"
+	}
+
+	return version
+}
+
+// goModPath returns module path declared by go.mod data, empty if there is none.
+func goModPath(data []byte) string {
+	for _, line := range strings.Split(string(data), "\n") {
+		if i := strings.Index(line, "//"); i != -1 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "module" {
+			return strings.Trim(fields[1], "\"`")
+		}
+	}
+
+	return ""
+}
+
+// hasMajorSuffix checks whether module path ends with v2+ major version suffix: /vN, or .vN for gopkg.in.
+func hasMajorSuffix(modPath string) bool {
+	i := strings.LastIndex(modPath, "/v")
+	if strings.HasPrefix(modPath, "gopkg.in/") {
+		i = strings.LastIndex(modPath, ".v")
+	}
+	if i == -1 {
+		return false
+	}
+	n, err := strconv.Atoi(modPath[i+2:])
+
+	return err == nil && n >= 2 && modPath[i+2] != '0'
+}
+
+// goVersion returns language version of the installed go, like 1.21, for go directive of exported go.mod.
+// Version of go ven is built with is used if go is not installed.
+func goVersion(ctx context.Context) string {
+	v := runtime.Version()
+	if out, err := runCmd(ctx, "", "go", "env", "GOVERSION"); err == nil && strings.TrimSpace(out) != "" {
+		v = strings.TrimSpace(out)
+	}
+	parts := strings.SplitN(strings.TrimPrefix(v, "go"), ".", 3)
+	if len(parts) < 2 {
+		return minGoVersion
+	}
+	if _, err := strconv.Atoi(parts[0]); err != nil {
+		return minGoVersion
+	}
+	minor := parts[1]
+	if i := strings.IndexFunc(minor, func(c rune) bool { return c < '0' || c > '9' }); i != -1 {
+		minor = minor[:i]
+	}
+	if minor == "" {
+		return minGoVersion
+	}
+
+	return parts[0] + "." + minor
+}
+
+// minGoVersion is go directive used if go version is unknown, like for development builds.
+const minGoVersion = "1.11"
+
+func formatGoMod(modPath, goVer string, modules []goModule) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "module %s\n\ngo %s\n", modPath, goVer)
+
+	if len(modules) != 0 {
+		b.WriteString("\nrequire (\n")
+		for _, mod := range modules {
+			fmt.Fprintf(&b, "\t%s %s\n", mod.Path, mod.Version)
+		}
+		b.WriteString(")\n")
+	}
+
+	var replaces []string
+	for _, mod := range modules {
+		if mod.Dir != "" {
+			replaces = append(replaces, fmt.Sprintf("\t%s => %s\n", mod.Path, mod.Dir))
+		}
+	}
+	if len(replaces) != 0 {
+		b.WriteString("\nreplace (\n")
+		b.WriteString(strings.Join(replaces, ""))
+		b.WriteString(")\n")
+	}
+
+	return b.Bytes()
+}
+
+// formatGoSum formats go.sum lines of modules, local modules are skipped like go does for dir replacements.
+func formatGoSum(modules []goModule) []byte {
+	var b bytes.Buffer
+	for _, mod := range modules {
+		if mod.Dir != "" {
+			continue
+		}
+
+		fmt.Fprintf(&b, "%s %s %s\n", mod.Path, mod.Version, mod.Sum)
+		fmt.Fprintf(&b, "%s %s/go.mod %s\n", mod.Path, mod.Version, hashGoMod(mod.GoMod))
+	}
+
+	return b.Bytes()
+}
+
+// hashGoMod computes h1 hash of a single go.mod file, unlike module files it's hashed without a path prefix.
+func hashGoMod(data []byte) string {
+	fileHash := sha256.Sum256(data)
+	h := sha256.New()
+	fmt.Fprintf(h, "%x  go.mod\n", fileHash)
+
+	return "h1:" + base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_pseudoVersion(t *testing.T) {
+	const (
+		timestamp = "20190102150405"
+		commit    = "0123456789abcdef0123456789abcdef01234567"
+	)
+	tests := []struct {
+		base string
+		want string
+	}{
+		{"", "v0.0.0-20190102150405-0123456789ab"},
+		{"v1.2.3", "v1.2.4-0.20190102150405-0123456789ab"},
+		{"v1.2.3-rc.1", "v1.2.3-rc.1.0.20190102150405-0123456789ab"},
+		{"v2.0.0", "v2.0.1-0.20190102150405-0123456789ab+incompatible"},
+	}
+	for _, tt := range tests {
+		if got := pseudoVersion(tt.base, timestamp, commit); got != tt.want {
+			t.Errorf("pseudoVersion(%q) = %s, want %s", tt.base, got, tt.want)
+		}
+	}
+}
+
+func Test_incompatibleVersion(t *testing.T) {
+	tests := map[string]string{
+		"v0.1.0":       "v0.1.0",
+		"v1.9.9":       "v1.9.9",
+		"v2.0.0":       "v2.0.0+incompatible",
+		"v3.1.0-beta":  "v3.1.0-beta+incompatible",
+		"not-a-semver": "not-a-semver",
+	}
+	for version, want := range tests {
+		if got := incompatibleVersion(version); got != want {
+			t.Errorf("incompatibleVersion(%s) = %s, want %s", version, got, want)
+		}
+	}
+}
+
+func Test_formatGoMod(t *testing.T) {
+	got := formatGoMod("example.com/proj", "1.16", []goModule{
+		{Path: "github.com/fx/a", Version: "v1.0.0"},
+		{Path: "github.com/fx/local", Version: "v0.0.0", Dir: "/gopath/src/github.com/fx/local"},
+	})
+	want := `module example.com/proj
+
+go 1.16
+
+require (
+	github.com/fx/a v1.0.0
+	github.com/fx/local v0.0.0
+)
+
+replace (
+	github.com/fx/local => /gopath/src/github.com/fx/local
+)
+`
+	if string(got) != want {
+		t.Errorf("formatGoMod() = %s, want %s", got, want)
+	}
+
+	if got := string(formatGoMod("example.com/proj", "1.16", nil)); got != "module example.com/proj\n\ngo 1.16\n" {
+		t.Errorf("formatGoMod() without modules = %q", got)
+	}
+}
+
+func Test_goVersion(t *testing.T) {
+	v := goVersion(context.Background())
+	if parts := strings.Split(v, "."); len(parts) != 2 || parts[0] != "1" {
+		t.Errorf("goVersion() = %s, want 1.N", v)
+	}
+}
+
+func Test_hasMajorSuffix(t *testing.T) {
+	tests := map[string]bool{
+		"github.com/fx/a":        false,
+		"github.com/fx/a/v2":     true,
+		"github.com/fx/a/v1":     false,
+		"github.com/fx/a/v02":    false,
+		"github.com/v2ray/core":  false,
+		"gopkg.in/yaml.v2":       true,
+		"gopkg.in/check.v1":      false,
+		"github.com/fx/a/v10":    true,
+		"github.com/fx/a/vendor": false,
+	}
+	for modPath, want := range tests {
+		if got := hasMajorSuffix(modPath); got != want {
+			t.Errorf("hasMajorSuffix(%s) = %v, want %v", modPath, got, want)
+		}
+	}
+}
+
+func Test_getGoModule(t *testing.T) {
+	dir := setupProject(t)
+	addTestRepo(t, dir, "github.com/fx/a", []testCommit{
+		{Files: map[string]string{"a.go": "package a\n", "go.mod": "module github.com/fx/a/v2 // v2\n"}, Tag: "v2.0.0"},
+	})
+	addTestRepo(t, dir, "github.com/fx/b", []testCommit{
+		{Files: map[string]string{"b.go": "package b\n"}, Tag: "v2.1.0"},
+	})
+	fetchTestProject(t, "github.com/fx/a", "github.com/fx/b")
+
+	tests := []struct {
+		pkg, wantPath, wantVersion string
+	}{
+		{"github.com/fx/a", "github.com/fx/a/v2", "v2.0.0"},
+		{"github.com/fx/b", "github.com/fx/b", "v2.1.0+incompatible"},
+	}
+	for _, tt := range tests {
+		mod, err := getGoModule(context.Background(), tt.pkg, manifest.Packages[tt.pkg], false, false)
+		if err != nil {
+			t.Errorf("getGoModule(%s) error: %v", tt.pkg, err)
+			continue
+		}
+		if mod.Path != tt.wantPath || mod.Version != tt.wantVersion {
+			t.Errorf("getGoModule(%s) = %s %s, want %s %s", tt.pkg, mod.Path, mod.Version, tt.wantPath, tt.wantVersion)
+		}
+	}
+}
+
+func Test_hashGoMod(t *testing.T) {
+	got := hashGoMod([]byte("module example.com/m\n"))
+	if want := "h1:flS2VctbRrTv+sBE+VKgxx6hlkMGPVz9MGOmzMYFg3k="; got != want {
+		t.Errorf("hashGoMod() = %s, want %s", got, want)
+	}
+}
+
+func Test_hashModule(t *testing.T) {
+	dir := t.TempDir()
+	module := map[string]string{
+		"a.go":           "package a\n",
+		"go.mod":         "module github.com/fx/a\n",
+		"LICENSE":        "license\n",
+		"a_test.go":      "package a\n",
+		"sub/sub.go":     "package sub\n",
+		"testdata/x.txt": "x\n",
+		"vendor/doc.go":  "package vendor\n",
+	}
+	skipped := map[string]string{
+		"nested/go.mod":            "module github.com/fx/a/nested\n",
+		"nested/nested.go":         "package nested\n",
+		"vendor/github.com/x/x.go": "package x\n",
+		"sub/vendor/y/y.go":        "package y\n",
+	}
+	files := make(map[string]string)
+	for name, content := range module {
+		files[name] = content
+	}
+	for name, content := range skipped {
+		files[name] = content
+	}
+	hashes := initTestRepo(t, filepath.Join(dir, "repo"), []testCommit{{Files: files}})
+
+	const prefix = "github.com/fx/a@v1.0.0/"
+	got, err := hashModule(context.Background(), filepath.Join(dir, "repo"), hashes[0], prefix)
+	if err != nil {
+		t.Fatalf("hashModule() error: %v", err)
+	}
+
+	for name, content := range module {
+		writeFile(t, filepath.Join(dir, "module", name), content)
+	}
+	want, err := hashDir(filepath.Join(dir, "module"), prefix, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("hashModule() = %s, want %s of module files only", got, want)
+	}
+}
@@ -5,27 +5,45 @@ import (
 	"fmt"
 )
 
-// Fetch fetches dependencies for current project.
-func Fetch(ctx context.Context, pkg string, update, verbose bool) error {
-	if _, err := getPkgImportsFromPopularVendorTools(pkg, "./", verbose); err != nil && verbose {
+// Fetch fetches dependencies for current project using jobs parallel workers.
+func Fetch(ctx context.Context, pkg string, update bool, jobs int, verbose bool) error {
+	if _, err := getPkgImportsFromPopularVendorTools(pkg, "./", true, verbose); err != nil && verbose {
 		fmt.Println(err)
 	}
 
-	_, _, depsMap, err := getPkgImports(pkg, Package{}, nil, "./", true, true, true, verbose)
+	return fetchProject(ctx, pkg, update, jobs, verbose)
+}
+
+// fetchProject imports dependencies of the project, versions pinned by the project lock file have to be cached already.
+func fetchProject(ctx context.Context, pkg string, update bool, jobs int, verbose bool) error {
+	deps, testDeps, err := getProjectImports(pkg, verbose)
 	if err != nil {
-		return fmt.Errorf("failed get imports for a project: %v", err)
+		return err
 	}
+	depsMap := make(map[string][]string, len(deps))
+	mergeDeps(depsMap, deps)
+	mergeDeps(depsMap, testDeps)
 
-	for importRoot, importDeps := range depsMap {
-		opts := ImportOptions{
-			Subpackages: importDeps,
-			Update:      update,
-			UpdateDeps:  update,
-		}
+	tasks := make([]importTask, 0, len(depsMap))
+	for _, importRoot := range sortedKeys(depsMap) {
+		tasks = append(tasks, importTask{
+			pkg: importRoot,
+			opts: ImportOptions{
+				Subpackages: depsMap[importRoot],
+				Update:      update,
+				UpdateDeps:  update,
+			},
+		})
+	}
 
-		if err := importPackage(ctx, importRoot, opts, verbose); err != nil {
-			return err
-		}
+	if err := importPackages(ctx, tasks, jobs, verbose); err != nil {
+		return err
+	}
+	if err := reportConflicts(); err != nil {
+		return err
+	}
+	if includeProjectTests() {
+		markTestPkgs(deps)
 	}
 	if err := saveManifest(); err != nil {
 		return err
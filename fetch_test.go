@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFetchDeterministic(t *testing.T) {
+	dir := setupProject(t)
+	addTestRepo(t, dir, "github.com/fx/a", []testCommit{
+		{Files: map[string]string{
+			"a.go":       "package a\n\nimport (\n\t_ \"github.com/fx/c\"\n\t_ \"github.com/fx/e/sub\"\n)\n",
+			"glide.lock": "imports:\n- name: github.com/fx/c\n  version: v1.0.0\n",
+		}},
+	})
+	addTestRepo(t, dir, "github.com/fx/b", []testCommit{
+		{Files: map[string]string{
+			"b.go":        "package b\n\nimport _ \"github.com/fx/c/util\"\n",
+			"Gopkg.lock":  "[[projects]]\n  name = \"github.com/fx/c\"\n  revision = \"v1.1.0\"\n",
+			"README.md":   "b\n",
+			"LICENSE.txt": "b\n",
+		}},
+	})
+	addTestRepo(t, dir, "github.com/fx/c", []testCommit{
+		{Files: map[string]string{"c.go": "package c\n", "util/util.go": "package util\n"}, Tag: "v1.0.0"},
+		{Files: map[string]string{"c.go": "package c\n\nconst V = 1\n"}, Tag: "v1.1.0"},
+		{Files: map[string]string{"c.go": "package c\n\nconst V = 2\n"}, Tag: "v2.0.0"},
+	})
+	// d has lock files of two tools pinning different versions, dep takes precedence.
+	addTestRepo(t, dir, "github.com/fx/d", []testCommit{
+		{Files: map[string]string{
+			"d.go":       "package d\n\nimport _ \"github.com/fx/e\"\n",
+			"glide.lock": "imports:\n- name: github.com/fx/e\n  version: v1.0.0\n",
+			"Gopkg.lock": "[[projects]]\n  name = \"github.com/fx/e\"\n  revision = \"v1.1.0\"\n",
+		}},
+	})
+	addTestRepo(t, dir, "github.com/fx/e", []testCommit{
+		{Files: map[string]string{"e.go": "package e\n", "sub/sub.go": "package sub\n"}, Tag: "v1.0.0"},
+		{Files: map[string]string{"e.go": "package e\n\nconst V = 1\n"}, Tag: "v1.1.0"},
+	})
+
+	var wantManifest, wantVendor string
+	for i := 0; i < 5; i++ {
+		proj, err := ioutil.TempDir(dir, "proj")
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeFile(t, filepath.Join(proj, "main.go"), "package main\n\nimport (\n\t_ \"github.com/fx/a\"\n\t_ \"github.com/fx/b\"\n\t_ \"github.com/fx/d\"\n)\n\nfunc main() {}\n")
+		if err := os.Chdir(proj); err != nil {
+			t.Fatal(err)
+		}
+
+		resetImportState()
+		if err := Fetch(context.Background(), "example.com/proj", false, 4, false); err != nil {
+			t.Fatalf("run %d: Fetch() error: %v", i, err)
+		}
+
+		data, err := ioutil.ReadFile("Manifest.yml")
+		if err != nil {
+			t.Fatal(err)
+		}
+		vendorHash, err := hashDir("vendor", "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if i == 0 {
+			wantManifest, wantVendor = string(data), vendorHash
+			if e := manifest.Packages["github.com/fx/e"]; e.Version != "v1.1.0" {
+				t.Errorf("github.com/fx/e version = %s, want v1.1.0 pinned by dep lock file", e.Version)
+			}
+			if c := manifest.Packages["github.com/fx/c"]; c.Version != "v1.1.0" {
+				t.Errorf("github.com/fx/c version = %s, want v1.1.0 as the highest requested", c.Version)
+			}
+			continue
+		}
+		if string(data) != wantManifest {
+			t.Errorf("run %d: Manifest.yml differs:\n%s\nwant:\n%s", i, data, wantManifest)
+		}
+		if vendorHash != wantVendor {
+			t.Errorf("run %d: vendor hash = %s, want %s", i, vendorHash, wantVendor)
+		}
+	}
+}
+
+func TestFetchTests(t *testing.T) {
+	dir := setupProject(t)
+	addTestRepo(t, dir, "github.com/fx/a", []testCommit{
+		{Files: map[string]string{"a.go": "package a\n", "a_test.go": "package a\n\nimport _ \"github.com/fx/u\"\n"}},
+	})
+	addTestRepo(t, dir, "github.com/fx/t", []testCommit{
+		{Files: map[string]string{"t.go": "package t\n\nimport _ \"github.com/fx/u\"\n"}},
+	})
+	addTestRepo(t, dir, "github.com/fx/u", []testCommit{
+		{Files: map[string]string{"u.go": "package u\n"}},
+	})
+
+	for _, includeTests := range []string{testsNone, "", testsProject} {
+		proj, err := ioutil.TempDir(dir, "proj")
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeFiles(t, proj, map[string]string{
+			"main.go":      "package main\n\nimport _ \"github.com/fx/a\"\n\nfunc main() {}\n",
+			"main_test.go": "package main\n\nimport _ \"github.com/fx/t\"\n",
+		})
+		if err := os.Chdir(proj); err != nil {
+			t.Fatal(err)
+		}
+
+		resetImportState()
+		manifest.IncludeTests = includeTests
+		if err := Fetch(context.Background(), "example.com/proj", false, 2, false); err != nil {
+			t.Fatalf("%q: Fetch() error: %v", includeTests, err)
+		}
+
+		if _, ok := manifest.Packages["github.com/fx/a"]; !ok {
+			t.Errorf("%q: github.com/fx/a is not fetched", includeTests)
+		}
+		if _, err := os.Stat("vendor/github.com/fx/a/a_test.go"); !os.IsNotExist(err) {
+			t.Errorf("%q: test file of github.com/fx/a is vendored: %v", includeTests, err)
+		}
+		// unset include_tests means none, test imports are vendored only on request.
+		if includeTests != testsProject {
+			if len(manifest.Packages) != 1 {
+				t.Errorf("%q: fetched %d packages, want only github.com/fx/a", includeTests, len(manifest.Packages))
+			}
+			continue
+		}
+		for _, name := range []string{"github.com/fx/a", "github.com/fx/t", "github.com/fx/u"} {
+			info, ok := manifest.Packages[name]
+			if !ok {
+				t.Errorf("%q: %s is not fetched", includeTests, name)
+				continue
+			}
+			if want := name != "github.com/fx/a"; info.Test != want {
+				t.Errorf("%q: %s test = %v, want %v", includeTests, name, info.Test, want)
+			}
+		}
+	}
+}
+
+func TestFetchProjectLockHints(t *testing.T) {
+	dir := setupProject(t)
+	addTestRepo(t, dir, "github.com/fx/a", []testCommit{
+		{Files: map[string]string{
+			"a.go":       "package a\n\nimport _ \"github.com/fx/c\"\n",
+			"glide.lock": "imports:\n- name: github.com/fx/c\n  version: v1.1.0\n",
+		}},
+	})
+	addTestRepo(t, dir, "github.com/fx/c", []testCommit{
+		{Files: map[string]string{"c.go": "package c\n"}, Tag: "v1.0.0"},
+		{Files: map[string]string{"c.go": "package c\n\nconst V = 1\n"}, Tag: "v1.1.0"},
+	})
+
+	writeFiles(t, ".", map[string]string{
+		"main.go":    "package main\n\nimport (\n\t_ \"github.com/fx/a\"\n\t_ \"github.com/fx/c\"\n)\n\nfunc main() {}\n",
+		"glide.lock": "imports:\n- name: github.com/fx/c\n  version: v1.0.0\n",
+	})
+
+	if err := Fetch(context.Background(), "example.com/proj", false, 2, false); err != nil {
+		t.Fatalf("Fetch() error: %v", err)
+	}
+
+	// project lock file is a hint like lock files of dependencies, only migrate pins its versions.
+	for pkg, reqs := range cachedConstraints {
+		for _, req := range reqs {
+			if req.Project {
+				t.Errorf("pkg (%s): version %s requested by %s is pinned as project version", pkg, req.Version, req.Requester)
+			}
+		}
+	}
+	if c := manifest.Packages["github.com/fx/c"]; c.Version != "v1.1.0" {
+		t.Errorf("github.com/fx/c version = %s, want v1.1.0 as the highest requested", c.Version)
+	}
+}
+
+func TestFetchSiblingConflict(t *testing.T) {
+	dir := setupProject(t)
+	// a, b and c are imported on the same level, so lock files of a and b are read after c is planned.
+	addTestRepo(t, dir, "github.com/fx/a", []testCommit{
+		{Files: map[string]string{
+			"a.go":       "package a\n\nimport _ \"github.com/fx/c\"\n",
+			"glide.lock": "imports:\n- name: github.com/fx/c\n  version: v1.0.0\n",
+		}},
+	})
+	addTestRepo(t, dir, "github.com/fx/b", []testCommit{
+		{Files: map[string]string{
+			"b.go":       "package b\n\nimport _ \"github.com/fx/c\"\n",
+			"Gopkg.lock": "[[projects]]\n  name = \"github.com/fx/c\"\n  revision = \"v1.1.0\"\n",
+		}},
+	})
+	addTestRepo(t, dir, "github.com/fx/c", []testCommit{
+		{Files: map[string]string{"c.go": "package c\n"}, Tag: "v1.0.0"},
+		{Files: map[string]string{"c.go": "package c\n\nconst V = 1\n"}, Tag: "v1.1.0"},
+		{Files: map[string]string{"c.go": "package c\n\nconst V = 2\n"}, Tag: "v2.0.0"},
+	})
+
+	writeFile(t, "main.go", "package main\n\nimport (\n\t_ \"github.com/fx/a\"\n\t_ \"github.com/fx/b\"\n\t_ \"github.com/fx/c\"\n)\n\nfunc main() {}\n")
+
+	out := captureStdout(t, func() {
+		if err := Fetch(context.Background(), "example.com/proj", false, 2, false); err != nil {
+			t.Fatalf("Fetch() error: %v", err)
+		}
+	})
+
+	if c := manifest.Packages["github.com/fx/c"]; c.Version != "v1.1.0" {
+		t.Errorf("github.com/fx/c version = %s, want v1.1.0 as the highest requested", c.Version)
+	}
+	data, err := ioutil.ReadFile("vendor/github.com/fx/c/c.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "package c\n\nconst V = 1\n"; string(data) != want {
+		t.Errorf("vendored github.com/fx/c = %q, want %q of v1.1.0", data, want)
+	}
+	for _, want := range []string{"resolved with (highest) policy", "v1.1.0 (selected)"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("conflicts report does not contain %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "v1.0.0 (selected)") {
+		t.Errorf("conflicts report selects v1.0.0:\n%s", out)
+	}
+}
+
+func TestFetchVenConstraints(t *testing.T) {
+	dir := setupProject(t)
+	// ven manifests of a and b constrain c with ranges, c has to satisfy both of them.
+	addTestRepo(t, dir, "github.com/fx/a", []testCommit{
+		{Files: map[string]string{
+			"a.go":         "package a\n\nimport _ \"github.com/fx/c\"\n",
+			"Manifest.yml": "constraints:\n  github.com/fx/c: ^1.0.0\n",
+		}},
+	})
+	addTestRepo(t, dir, "github.com/fx/b", []testCommit{
+		{Files: map[string]string{
+			"b.go":         "package b\n\nimport _ \"github.com/fx/c\"\n",
+			"Manifest.yml": "constraints:\n  github.com/fx/c: <1.2.0\n",
+		}},
+	})
+	addTestRepo(t, dir, "github.com/fx/c", []testCommit{
+		{Files: map[string]string{"c.go": "package c\n"}, Tag: "v1.0.0"},
+		{Files: map[string]string{"c.go": "package c\n\nconst V = 1\n"}, Tag: "v1.1.0"},
+		{Files: map[string]string{"c.go": "package c\n\nconst V = 2\n"}, Tag: "v1.2.0"},
+		{Files: map[string]string{"c.go": "package c\n\nconst V = 3\n"}, Tag: "v2.0.0"},
+	})
+
+	writeFile(t, "main.go", "package main\n\nimport (\n\t_ \"github.com/fx/a\"\n\t_ \"github.com/fx/b\"\n)\n\nfunc main() {}\n")
+
+	if err := Fetch(context.Background(), "example.com/proj", false, 2, false); err != nil {
+		t.Fatalf("Fetch() error: %v", err)
+	}
+	if c := manifest.Packages["github.com/fx/c"]; c.Version != "v1.1.0" {
+		t.Errorf("github.com/fx/c version = %s, want v1.1.0 as the highest satisfying ^1.0.0 and <1.2.0", c.Version)
+	}
+}
@@ -4,8 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"go/build"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 )
 
 // copyDir copies a directory recursively.
@@ -97,3 +101,92 @@ func vendorExists() bool {
 
 	return false
 }
+
+// currentPkg detects import path of a project in the current directory.
+func currentPkg() (string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("cannot get current directory: %v", err)
+	}
+
+	return strings.TrimPrefix(dir, os.Getenv("GOPATH")+"/src/"), nil
+}
+
+// gopathPkgDir returns dir of pkg in the first GOPATH entry which has it.
+func gopathPkgDir(pkg string) (string, bool) {
+	for _, gopath := range filepath.SplitList(build.Default.GOPATH) {
+		dir := filepath.Join(gopath, "src", filepath.FromSlash(pkg))
+		if _, err := os.Stat(dir); err == nil {
+			return dir, true
+		}
+	}
+
+	return "", false
+}
+
+// dirStash keeps directories moved aside, so their removal can be rolled back.
+type dirStash struct {
+	dir   string
+	moved [][2]string
+}
+
+// stashDirs moves directories, or single files, to a temporary location next to vendor.
+// Either all existing paths are moved, or none of them.
+func stashDirs(dirs []string) (*dirStash, error) {
+	tmp, err := ioutil.TempDir(filepath.Dir(manifest.VendorPath), ".ven-removed")
+	if err != nil {
+		return nil, err
+	}
+	stash := &dirStash{dir: tmp}
+
+	sorted := make([]string, len(dirs))
+	copy(sorted, dirs)
+	sort.Strings(sorted)
+
+	for i, dir := range sorted {
+		// nested dir could be already moved together with its parent.
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			continue
+		}
+
+		dest := fmt.Sprintf("%s/%d", tmp, i)
+		if err := os.Rename(dir, dest); err != nil {
+			if restoreErr := stash.Restore(); restoreErr != nil {
+				return nil, fmt.Errorf("cannot move %s: %v; cannot restore: %v", dir, err, restoreErr)
+			}
+			return nil, fmt.Errorf("cannot move %s: %v", dir, err)
+		}
+		stash.moved = append(stash.moved, [2]string{dir, dest})
+	}
+
+	return stash, nil
+}
+
+// Restore moves stashed directories back.
+func (s *dirStash) Restore() error {
+	for i := len(s.moved) - 1; i >= 0; i-- {
+		if err := os.Rename(s.moved[i][1], s.moved[i][0]); err != nil {
+			return err
+		}
+	}
+	s.moved = nil
+
+	return os.RemoveAll(s.dir)
+}
+
+// Drop deletes stashed directories.
+func (s *dirStash) Drop() error {
+	s.moved = nil
+
+	return os.RemoveAll(s.dir)
+}
+
+// removeEmptyParents removes empty parent directories of path up to stop dir.
+func removeEmptyParents(path, stop string) {
+	stop = filepath.Clean(stop)
+	for dir := filepath.Dir(filepath.Clean(path)); dir != stop && dir != "." && dir != "/"; dir = filepath.Dir(dir) {
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// toolchainExts are extensions of non-go files go toolchain builds packages with:
+// cgo sources and headers, assembler, fortran, swig and object files.
+var toolchainExts = map[string]struct{}{
+	".c": {}, ".cc": {}, ".cpp": {}, ".cxx": {}, ".m": {}, ".h": {}, ".hh": {}, ".hpp": {}, ".hxx": {},
+	".f": {}, ".F": {}, ".for": {}, ".f90": {}, ".s": {}, ".S": {}, ".sx": {}, ".asm": {},
+	".swig": {}, ".swigcxx": {}, ".syso": {}, ".o": {},
+}
+
+// licenseNames are names of license and notice files, which are kept in every vendored dir.
+var licenseNames = []string{"LICENSE", "LICENCE", "COPYING", "COPYRIGHT", "NOTICE", "PATENTS", "UNLICENSE"}
+
+// isLicenseFile checks whether file is a license or a notice, like LICENSE, license.txt, COPYING.LESSER or LICENSE-MIT.
+func isLicenseFile(name string) bool {
+	name = strings.ToUpper(name)
+	for _, l := range licenseNames {
+		if name == l {
+			return true
+		}
+		if strings.HasPrefix(name, l) && strings.ContainsAny(name[len(l):len(l)+1], ".-_") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// pkgKeepFiles returns globs of non-go files kept for pkg: package override, or manifest keep_files.
+func pkgKeepFiles(pkg string) []string {
+	if globs, ok := manifest.PkgKeepFiles[pkg]; ok {
+		return globs
+	}
+
+	return manifest.KeepFiles
+}
+
+// keepFile checks whether non-go file is kept, rel is a slash separated path of a file in pkg dir.
+// Glob without a slash matches file name in any dir, others match the whole path.
+func keepFile(rel string, globs []string) bool {
+	if isLicenseFile(path.Base(rel)) {
+		return true
+	}
+	for _, glob := range globs {
+		name := rel
+		if !strings.Contains(glob, "/") {
+			name = path.Base(rel)
+		}
+		if ok, _ := path.Match(glob, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// filterPkgFiles removes files not needed to build pkg vendored in dir: tests, excluded dirs and non-go files,
+// except files compiled by go toolchain, files referenced by go:embed and cgo, license files and files matching keep_files.
+func filterPkgFiles(pkg, dir string) error {
+	refs, err := pkgReferencedFiles(dir)
+	if err != nil {
+		return err
+	}
+
+	var (
+		dirs []string
+		// excludedDirs are excluded dirs kept because of referenced files, only these files are kept in them.
+		excludedDirs []string
+		globs        = pkgKeepFiles(pkg)
+	)
+
+	err = filepath.Walk(dir, func(path string, f os.FileInfo, err error) error {
+		if err != nil || f == nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			if _, err = os.Stat(path); os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if _, ok := refs[filepath.Clean(path)]; ok {
+			return nil
+		}
+		if f.IsDir() {
+			if path != dir && dirIsExcluded(path) {
+				if hasRefsIn(refs, path) {
+					excludedDirs = append(excludedDirs, path)
+					dirs = append(dirs, path)
+					return nil
+				}
+				if err := os.RemoveAll(path); err != nil && !os.IsNotExist(err) {
+					return fmt.Errorf("fail delete (%s): %v", path, err)
+				}
+				return filepath.SkipDir
+			}
+			dirs = append(dirs, path)
+
+			return nil
+		}
+		if !isInDirs(path, excludedDirs) && keepPkgFile(dir, path, globs) {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("fail delete (%s): %v", path, err)
+		}
+
+		return nil
+	})
+
+	// nested dirs go after their parents, so empty dirs are removed bottom up.
+	for i := len(dirs) - 1; i >= 0; i-- {
+		files, err := ioutil.ReadDir(dirs[i])
+		if err != nil {
+			continue
+		}
+		if len(files) == 0 {
+			os.Remove(dirs[i])
+		}
+	}
+
+	return err
+}
+
+// keepPkgFile checks whether file at path of pkg vendored in dir is needed.
+func keepPkgFile(dir, path string, globs []string) bool {
+	if strings.HasSuffix(path, ".go") {
+		return !strings.HasSuffix(path, "_test.go") || includePkgTests()
+	}
+	if _, ok := toolchainExts[filepath.Ext(path)]; ok {
+		return true
+	}
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+
+	return keepFile(filepath.ToSlash(rel), globs)
+}
+
+// hasRefsIn checks whether any of refs is inside dir.
+func hasRefsIn(refs map[string]struct{}, dir string) bool {
+	prefix := filepath.Clean(dir) + string(filepath.Separator)
+	for ref := range refs {
+		if strings.HasPrefix(ref, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isInDirs(path string, dirs []string) bool {
+	for _, d := range dirs {
+		if strings.HasPrefix(path, d+string(filepath.Separator)) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_filterPkgFiles(t *testing.T) {
+	setupProject(t)
+	manifest.KeepFiles = []string{"*.tmpl", "sql/*.sql"}
+	manifest.PkgKeepFiles = map[string][]string{"github.com/pkg/other": {"*.md"}}
+
+	files := []string{
+		"LICENSE", "NOTICE.txt", "README.md", "a.go", "a_test.go", "a.c", "Makefile",
+		"sub/COPYING.LESSER", "sub/sub.go", "sub/license-mit", "sub/licenses.go.txt",
+		"templates/page.tmpl", "sql/init.sql", "nested/sql/init.sql", "docs/guide.md",
+	}
+	cases := []struct {
+		pkg  string
+		want []string
+	}{
+		{
+			pkg: "github.com/pkg/lib",
+			want: []string{
+				"LICENSE", "NOTICE.txt", "a.c", "a.go", "sql/init.sql",
+				"sub/COPYING.LESSER", "sub/license-mit", "sub/sub.go", "templates/page.tmpl",
+			},
+		},
+		{
+			pkg:  "github.com/pkg/other",
+			want: []string{"LICENSE", "NOTICE.txt", "README.md", "a.c", "a.go", "docs/guide.md", "sub/COPYING.LESSER", "sub/license-mit", "sub/sub.go"},
+		},
+	}
+
+	for _, c := range cases {
+		dir := t.TempDir()
+		for _, file := range files {
+			writeFile(t, filepath.Join(dir, file), file)
+		}
+
+		if err := filterPkgFiles(c.pkg, dir); err != nil {
+			t.Fatalf("%s: filterPkgFiles() error: %v", c.pkg, err)
+		}
+
+		if got := listFiles(t, dir); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: kept files = %v, want %v", c.pkg, got, c.want)
+		}
+	}
+}
+
+func Test_filterPkgFilesReferenced(t *testing.T) {
+	setupProject(t)
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"embed.go": "package lib\n\nimport \"embed\"\n\n//go:embed templates/*.tmpl \"static dir\" all:_assets\n" +
+			"//go:embed migrations testdata/data.json\nvar files embed.FS\n",
+		"cgo.go": "package lib\n\n/*\n#cgo CFLAGS: -I${SRCDIR}/include\n#cgo LDFLAGS: ${SRCDIR}/lib/libfoo.a -lm\n" +
+			"#include <stdio.h>\n#include \"local.inc\"\n*/\nimport \"C\"\n",
+		"templates/a.tmpl":        "",
+		"templates/b.txt":         "",
+		"static dir/app.css":      "",
+		"static dir/.hidden":      "",
+		"_assets/.keep":           "",
+		"migrations/001.sql":      "",
+		"migrations/_skip.sql":    "",
+		"testdata/data.json":      "",
+		"testdata/other.json":     "",
+		"include/foo.inc":         "",
+		"lib/libfoo.a":            "",
+		"lib/README":              "",
+		"local.inc":               "",
+		"src/x.cpp":               "",
+		"src/x.hpp":               "",
+		"rsrc_windows_amd64.syso": "",
+		"view.m":                  "",
+		"README.md":               "",
+	})
+
+	if err := filterPkgFiles("github.com/pkg/lib", dir); err != nil {
+		t.Fatalf("filterPkgFiles() error: %v", err)
+	}
+
+	want := []string{
+		"_assets/.keep", "cgo.go", "embed.go", "include/foo.inc", "lib/libfoo.a", "local.inc", "migrations/001.sql",
+		"rsrc_windows_amd64.syso", "src/x.cpp", "src/x.hpp", "static dir/app.css", "templates/a.tmpl", "testdata/data.json", "view.m",
+	}
+	if got := listFiles(t, dir); !reflect.DeepEqual(got, want) {
+		t.Errorf("kept files = %v, want %v", got, want)
+	}
+}
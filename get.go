@@ -35,6 +35,9 @@ func Get(ctx context.Context, pkgs []string, update, updateDeps, constraint, ver
 			return newPkgs, err
 		}
 	}
+	if err := reportConflicts(); err != nil {
+		return newPkgs, err
+	}
 	if err := saveManifest(); err != nil {
 		return newPkgs, err
 	}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"sort"
+)
+
+// getProjectDeps scans project sources for imports. Returns imported root packages mapped to used subpackages.
+// Test imports are included if manifest includes project tests.
+func getProjectDeps(pkg string, verbose bool) (map[string][]string, error) {
+	depsMap, testDeps, err := getProjectImports(pkg, verbose)
+	if err != nil {
+		return nil, err
+	}
+	mergeDeps(depsMap, testDeps)
+
+	return depsMap, nil
+}
+
+// PkgDepRoots returns sorted root packages pkg and its tests depend on.
+// Dependencies missing in manifest are returned as is.
+func (m *Manifest) PkgDepRoots(pkg string) []string {
+	if _, ok := m.Packages[pkg]; !ok {
+		return nil
+	}
+
+	rootsMap := make(map[string]struct{})
+	for _, dep := range m.PkgDeps(pkg) {
+		root := getPkgRoot(dep)
+		if _, existingRoot, exists := m.PkgExists(dep); exists {
+			root = existingRoot
+		}
+		if root != pkg {
+			rootsMap[root] = struct{}{}
+		}
+	}
+
+	roots := make([]string, 0, len(rootsMap))
+	for root := range rootsMap {
+		roots = append(roots, root)
+	}
+	sort.Strings(roots)
+
+	return roots
+}
+
+// PkgDeps returns imports of pkg and its tests.
+func (m *Manifest) PkgDeps(pkg string) []string {
+	info := m.Packages[pkg]
+	deps := make([]string, 0, len(info.Deps)+len(info.TestDeps))
+	for dep := range info.Deps {
+		deps = append(deps, dep)
+	}
+	for dep := range info.TestDeps {
+		deps = append(deps, dep)
+	}
+
+	return deps
+}
+
+// ReachablePkgs returns manifest packages reachable from roots through pkg dependencies.
+// Packages listed in skip are neither included nor traversed.
+func (m *Manifest) ReachablePkgs(roots []string, skip map[string]struct{}) map[string]struct{} {
+	reachable := make(map[string]struct{})
+	queue := make([]string, 0, len(roots))
+	for _, root := range roots {
+		if _, ok := m.Packages[root]; ok {
+			queue = append(queue, root)
+		}
+	}
+
+	for len(queue) != 0 {
+		pkg := queue[0]
+		queue = queue[1:]
+
+		if _, ok := skip[pkg]; ok {
+			continue
+		}
+		if _, ok := reachable[pkg]; ok {
+			continue
+		}
+		reachable[pkg] = struct{}{}
+
+		for _, dep := range m.PkgDepRoots(pkg) {
+			if _, ok := m.Packages[dep]; ok {
+				queue = append(queue, dep)
+			}
+		}
+	}
+
+	return reachable
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// hashDir computes hash of dir files in a go.sum h1 format:
+// sha256 over sorted lines of file sha256 and file path prefixed with prefix.
+// Files and directories for which skip returns true are not hashed.
+func hashDir(dir, prefix string, skip func(rel string, isDir bool) bool) (string, error) {
+	var files []string
+	err := filepath.Walk(dir, func(path string, f os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if f.IsDir() {
+			if rel != "." && skip != nil && skip(rel, true) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !f.Mode().IsRegular() || skip != nil && skip(rel, false) {
+			return nil
+		}
+		files = append(files, rel)
+
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	hashes := make(map[string][]byte, len(files))
+	for _, file := range files {
+		fileHash, err := hashFile(filepath.Join(dir, file))
+		if err != nil {
+			return "", err
+		}
+		hashes[file] = fileHash
+	}
+
+	return h1Sum(prefix, hashes)
+}
+
+// h1Sum computes go.sum h1 hash of files mapped to their sha256 hashes, file names are prefixed with prefix.
+func h1Sum(prefix string, hashes map[string][]byte) (string, error) {
+	files := make([]string, 0, len(hashes))
+	for file := range hashes {
+		if strings.Contains(file, "\n") {
+			return "", fmt.Errorf("file name %q contains newline", file)
+		}
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	h := sha256.New()
+	for _, file := range files {
+		fmt.Fprintf(h, "%x  %s\n", hashes[file], prefix+file)
+	}
+
+	return "h1:" + base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+}
+
+func hashFile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+
+	return h.Sum(nil), nil
+}
+
+// pkgHash computes hash of a vendored pkg, packages nested into pkg dir are hashed on their own.
+// Files of pruned subpackages are not hashed, so the hash is the same whether they are removed yet or not.
+func pkgHash(pkg string, pruned map[string]struct{}) (string, error) {
+	return hashDir(fmt.Sprintf("%s/%s", manifest.VendorPath, pkg), "", skipNestedPkgs(pkg, pruned))
+}
+
+// skipNestedPkgs skips dirs of manifest packages nested into pkg dir and files of pruned pkg subpackages.
+func skipNestedPkgs(pkg string, pruned map[string]struct{}) func(rel string, isDir bool) bool {
+	return func(rel string, isDir bool) bool {
+		if !isDir {
+			_, skip := pruned[pkg+"/"+path.Dir(rel)]
+			return skip
+		}
+		_, nested := manifest.Packages[pkg+"/"+rel]
+		return nested
+	}
+}
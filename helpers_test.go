@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/vcs"
+)
+
+// setupProject creates a temp dir with proj dir, which becomes the current dir, and repos dir ven resolves pkgs to,
+// see addTestRepo. Manifest and import state are reset, they are restored together with the current dir once t is done.
+// Returns the temp dir.
+func setupProject(t *testing.T) string {
+	dir := t.TempDir()
+	proj := filepath.Join(dir, "proj")
+	if err := os.MkdirAll(proj, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	origManifest, origOffline := manifest, offline
+	t.Cleanup(func() {
+		os.Chdir(wd)
+		resetImportState()
+		manifest, offline = origManifest, origOffline
+	})
+	if err := os.Chdir(proj); err != nil {
+		t.Fatal(err)
+	}
+	resetImportState()
+	useTestRepos(t, filepath.Join(dir, "repos"))
+
+	return dir
+}
+
+// writeMain writes main.go of the project in the current dir, which imports pkgs.
+func writeMain(t *testing.T, pkgs ...string) {
+	src := "package main\n\n"
+	switch len(pkgs) {
+	case 0:
+	case 1:
+		src += "import _ \"" + pkgs[0] + "\"\n\n"
+	default:
+		src += "import (\n"
+		for _, pkg := range pkgs {
+			src += "\t_ \"" + pkg + "\"\n"
+		}
+		src += ")\n\n"
+	}
+	writeFile(t, "main.go", src+"func main() {}\n")
+}
+
+// fetchTestProject fetches the project in the current dir, which imports pkgs.
+func fetchTestProject(t *testing.T, pkgs ...string) {
+	writeMain(t, pkgs...)
+	if err := Fetch(context.Background(), "example.com/proj", false, 2, false); err != nil {
+		t.Fatalf("Fetch() error: %v", err)
+	}
+}
+
+// reinstallProject removes vendor of the project in the current dir and installs it from manifest using jobs workers.
+func reinstallProject(t *testing.T, jobs int) error {
+	if err := os.RemoveAll("vendor"); err != nil {
+		t.Fatal(err)
+	}
+	cachedPkgs = make(map[string]struct{})
+
+	return Install(context.Background(), jobs, false)
+}
+
+// resetImportState resets manifest and import caches, so every fetch starts from scratch.
+func resetImportState() {
+	manifest = initManifest()
+	newPkgs = make([]string, 0, 10)
+	cachedPkgs = make(map[string]struct{})
+	cachedConstraints = make(map[string][]versionRequest)
+	cachedExcluded = make(map[string]struct{})
+	resolvedPkgs = make(map[string]resolvedPkg)
+}
+
+// testCommit describes commit of a test repo.
+type testCommit struct {
+	Files map[string]string
+	Tag   string
+}
+
+// addTestRepo creates repo of root pkg in repos dir of a project set up in dir, returns hashes of created commits.
+func addTestRepo(t *testing.T, dir, root string, commits []testCommit) []string {
+	return initTestRepo(t, filepath.Join(dir, "repos", root+".git"), commits)
+}
+
+// initTestRepo creates bare git repo in dir, returns hashes of created commits.
+func initTestRepo(t *testing.T, dir string, commits []testCommit) []string {
+	work := t.TempDir()
+	git := func(args ...string) string {
+		cmd := exec.Command("git", append([]string{"-C", work, "-c", "user.name=ven", "-c", "user.email=ven@example.com"}, args...)...)
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_DATE=2017-01-01T00:00:00Z", "GIT_COMMITTER_DATE=2017-01-01T00:00:00Z")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %s: %v: %s", strings.Join(args, " "), err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+
+	git("init", "--quiet")
+	hashes := make([]string, 0, len(commits))
+	for i, c := range commits {
+		writeFiles(t, work, c.Files)
+		git("add", "-A")
+		git("commit", "--quiet", "--allow-empty", "-m", "commit "+string(rune('a'+i)))
+		if c.Tag != "" {
+			git("tag", c.Tag)
+		}
+		hashes = append(hashes, git("rev-parse", "HEAD"))
+	}
+
+	if out, err := exec.Command("git", "clone", "--bare", "--quiet", work, dir).CombinedOutput(); err != nil {
+		t.Fatalf("git clone: %v: %s", err, out)
+	}
+
+	return hashes
+}
+
+// useTestRepos makes ven resolve pkgs to bare repos in dir named as <pkg>.git.
+func useTestRepos(t *testing.T, dir string) {
+	orig := repoRootForImportPath
+	repoRootForImportPath = func(importPath string, verbose bool) (*vcs.RepoRoot, error) {
+		root := getPkgRoot(importPath)
+		return &vcs.RepoRoot{VCS: vcs.ByCmd("git"), Repo: filepath.Join(dir, root), Root: root}, nil
+	}
+	t.Cleanup(func() { repoRootForImportPath = orig })
+	t.Setenv("VEN_CACHE_DIR", filepath.Join(dir, ".cache"))
+}
+
+func writeFile(t *testing.T, path, content string) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeFiles writes files, which are mapped by slash separated paths relative to dir.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		writeFile(t, filepath.Join(dir, filepath.FromSlash(name)), content)
+	}
+}
+
+// listFiles returns sorted slash separated paths of files in dir.
+func listFiles(t *testing.T, dir string) []string {
+	var files []string
+	err := filepath.Walk(dir, func(path string, f os.FileInfo, err error) error {
+		if err != nil || f.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		files = append(files, filepath.ToSlash(rel))
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+
+	return files
+}
+
+// captureStdout returns what fn prints to stdout.
+func captureStdout(t *testing.T, fn func()) string {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	out := make(chan string)
+	go func() {
+		data, _ := ioutil.ReadAll(r)
+		out <- string(data)
+	}()
+	fn()
+	w.Close()
+
+	return <-out
+}
@@ -1,10 +1,10 @@
 package main
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"fmt"
+	"go/ast"
 	"go/parser"
 	"go/token"
 	"io/ioutil"
@@ -14,17 +14,29 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
 
 	"github.com/cliqueinc/ven/parse"
 	"golang.org/x/tools/go/vcs"
 )
 
+// import state below is changed from the serial stages of importPackages only,
+// cachedConstraints is also filled from fetch workers, so it's guarded by importMu.
 var (
+	importMu sync.Mutex
+
 	newPkgs    = make([]string, 0, 10)
 	cachedPkgs = make(map[string]struct{})
-	// cachedConstraints keeps desired, but not required pkg versions for load (taken from other package managers)
-	cachedConstraints = make(map[string]string)
+	// cachedConstraints keeps desired, but not required pkg versions for load (taken from other package managers),
+	// together with packages requested them.
+	cachedConstraints = make(map[string][]versionRequest)
 	cachedExcluded    = make(map[string]struct{})
+	// resolvedPkgs keeps pkgs whose version was picked from cachedConstraints,
+	// they are resolved again once requests of the whole dependency graph are known.
+	resolvedPkgs = make(map[string]resolvedPkg)
+
+	// repoRootForImportPath detects pkg repository, replaceable in tests to avoid network.
+	repoRootForImportPath = vcs.RepoRootForImportPath
 )
 
 // ImportOptions describes import options.
@@ -48,111 +60,346 @@ type ImportOptions struct {
 	UpdateDeps bool
 }
 
+// importTask describes pkg to import.
+type importTask struct {
+	pkg  string
+	opts ImportOptions
+}
+
+// importPlan keeps pkg import state between planning, fetching and finishing stages.
+type importPlan struct {
+	task             importTask
+	rootPkg          string
+	version          string
+	isLocal          bool
+	isNewPkg         bool
+	performImport    bool
+	versionRequired  bool
+	updatePkgImports bool
+	newSubpkgs       []string
+	info             Package
+
+	// candidates are conflicting versions requested by dependencies, the newest one is imported.
+	candidates []string
+	// fromLock tells that version was requested by lock files of other tools.
+	fromLock bool
+	// resolved tells that version was picked from versions requested by dependencies, see resolveCachedConstraint.
+	resolved bool
+	// unprune tells that pruned subpackages are imported again, so pkg is fetched at the vendored commit.
+	unprune bool
+	// source is an alternate repository url or import path of pkg, like a fork.
+	source, sourceVCS string
+	fetchAll          bool
+
+	// src is resolved only if pkg needs to be fetched.
+	src repoSource
+
+	// fetch results
+	fetchedInfo Package
+	fetchErr    error
+}
+
+// repoSource describes where pkg repository is fetched from.
+type repoSource struct {
+	// Root is pkg root import path.
+	Root string
+	// Repo is a repository url, or a path in GOPATH for local pkgs.
+	Repo string
+	// VCS is a version control system command: git, hg, bzr or svn.
+	VCS   string
+	Local bool
+	// Source is set if pkg is fetched from an alternate repository url or import path.
+	Source string
+
+	// cacheRoot is a path of source repository in cache.
+	cacheRoot string
+}
+
+// CacheRoot returns path of pkg repository in cache.
+func (s repoSource) CacheRoot() string {
+	if s.cacheRoot != "" {
+		return s.cacheRoot
+	}
+
+	return s.Root
+}
+
 // importPackage imports package with it's dependencies.
 func importPackage(ctx context.Context, pkg string, opts ImportOptions, verbose bool) error {
+	return importPackages(ctx, []importTask{{pkg: pkg, opts: opts}}, 1, verbose)
+}
+
+// importPackages imports packages with their dependencies level by level.
+// Decisions about what to import and manifest updates are made serially,
+// while clone, checkout and filter of each level run on jobs workers.
+func importPackages(ctx context.Context, tasks []importTask, jobs int, verbose bool) error {
+	var errs importErrors
+
+	for len(tasks) != 0 {
+		if ctxCancelled(ctx) {
+			return ctx.Err()
+		}
+
+		var (
+			plans   []*importPlan
+			next    []importTask
+			planned = make(map[string]struct{})
+		)
+		for _, task := range tasks {
+			key := getPkgRoot(task.pkg)
+			if _, root, exists := manifest.PkgExists(key); exists {
+				key = root
+			}
+			// the same pkg can be required several times on a level, handle it after the first import is done.
+			if _, ok := planned[key]; ok {
+				next = append(next, task)
+				continue
+			}
+
+			plan, err := planImport(task, verbose)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if plan == nil {
+				continue
+			}
+
+			if plan.performImport {
+				src, err := resolveRepo(plan.rootPkg, plan.source, plan.sourceVCS, plan.isLocal)
+				if err != nil {
+					errs = append(errs, err)
+					continue
+				}
+				if _, ok := planned[src.Root]; ok {
+					next = append(next, task)
+					continue
+				}
+				plan.src = src
+				planned[src.Root] = struct{}{}
+			}
+			planned[key] = struct{}{}
+			plans = append(plans, plan)
+		}
+
+		runJobs(jobs, len(plans), func(i int) {
+			plan := plans[i]
+			if !plan.performImport {
+				return
+			}
+			if verbose {
+				fmt.Println(plan.src.Root, plan.version)
+			}
+			plan.fetchedInfo, _, plan.fetchErr = fetchRepo(ctx, plan.src, plan.version, plan.candidates, true, verbose, plan.versionRequired)
+		})
+
+		for _, plan := range plans {
+			depTasks, err := finishImport(ctx, plan, verbose)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			next = append(next, depTasks...)
+		}
+
+		tasks = next
+		if len(tasks) == 0 && len(errs) == 0 {
+			// requests of the whole graph are known now, pkgs resolved before some of them were found are imported again.
+			var err error
+			if tasks, err = reresolvePkgs(verbose); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	if offline {
+		errs = groupNotCached(errs)
+	}
+
+	return errs.errOrNil()
+}
+
+// planImport decides whether and how pkg needs to be imported, nil plan means nothing to do.
+func planImport(task importTask, verbose bool) (*importPlan, error) {
 	var (
-		updatePkgImports bool
-		performImport    = true
-		isNewPkg         = true
-		isUpdate         = opts.Update || opts.UpdateDeps
-		isLocal          = opts.Local
-		version          = opts.Version
-		newSubpkgs       = opts.Subpackages
-		versionRequired  bool
+		pkg, opts = task.pkg, task.opts
+		plan      = &importPlan{
+			task:          task,
+			isNewPkg:      true,
+			performImport: true,
+			isLocal:       opts.Local,
+			version:       opts.Version,
+			newSubpkgs:    opts.Subpackages,
+			fetchAll:      opts.FetchAll,
+		}
 	)
 
 	rootPkg := getPkgRoot(pkg)
 	if root, exclude := manifest.IsExcludedPkg(rootPkg); exclude {
 		if _, ok := cachedExcluded[root]; ok {
-			return nil
+			return nil, nil
 		}
 		if verbose {
 			fmt.Printf("pkg (%s) is excluded from import\n", root)
 		}
 		cachedExcluded[root] = struct{}{}
-		return nil
+		return nil, nil
 	}
 	if _, local := manifest.IsLocalPkg(rootPkg); local {
-		isLocal = true
+		plan.isLocal = true
 	}
 	if _, constraintVersion, exists := manifest.GetPkgConstraint(rootPkg); exists && constraintVersion != "" {
-		versionRequired = true
-		if version == "" {
-			version = constraintVersion
-		} else if version != constraintVersion {
-			return fmt.Errorf("pkg (%s): pkg has a constraint (%s), can't import version (%s)", rootPkg, constraintVersion, version)
+		plan.versionRequired = true
+		if plan.version == "" {
+			plan.version = constraintVersion
+			// project lock pins the exact commit, as long as its tag matches the constraint.
+			if pin, ok := projectPin(rootPkg); ok && !opts.Update && (pin.Tag == "" || pin.Tag == constraintVersion || constraintAllows(constraintVersion, pin.Tag)) {
+				plan.version, plan.fromLock = pin.Version, true
+				plan.source, plan.sourceVCS = pin.Source, pin.VCS
+			}
+		} else if plan.version != constraintVersion && !constraintAllows(constraintVersion, plan.version) {
+			return nil, fmt.Errorf("pkg (%s): pkg has a constraint (%s), can't import version (%s)", rootPkg, constraintVersion, plan.version)
+		}
+	} else if _, _, exists := manifest.PkgExists(rootPkg); plan.version == "" && (!exists || opts.Update) {
+		req, candidates, err := resolveCachedConstraint(rootPkg, !opts.Update)
+		if err != nil {
+			return nil, err
 		}
-	} else if v := cachedConstraints[rootPkg]; version == "" && v != "" {
-		version = v
+		plan.version, plan.candidates = req.Version, candidates
+		plan.fromLock = req.Version != "" || len(candidates) != 0
+		plan.resolved = true
+		plan.source, plan.sourceVCS = req.Source, req.VCS
+		// lock file knows which subpackages are used, no need to scan all of them.
+		if plan.fetchAll && len(req.Subpackages) != 0 {
+			plan.fetchAll = false
+			plan.newSubpkgs = append(plan.newSubpkgs, req.Subpackages...)
+		}
+	}
+	if existing, _, exists := manifest.PkgExists(rootPkg); exists && plan.source == "" {
+		plan.source, plan.sourceVCS = existing.Source, existing.VCS
 	}
 
-	var info Package
 	if existing, root, exists := manifest.PkgExists(rootPkg); exists {
 		rootPkg = root
 		if pkg != rootPkg {
-			newSubpkgs = append(newSubpkgs, pkg)
+			plan.newSubpkgs = append(plan.newSubpkgs, pkg)
 		}
-		if len(newSubpkgs) != 0 {
+		if len(plan.newSubpkgs) != 0 {
 			var i int
-			for _, subpkg := range newSubpkgs {
+			for _, subpkg := range plan.newSubpkgs {
 				if _, ok := existing.Subpackages[subpkg]; !ok {
 					if !dirIsExcluded(subpkg) {
-						newSubpkgs[i] = subpkg
+						plan.newSubpkgs[i] = subpkg
 						i++
 					}
 				}
 			}
-			newSubpkgs = newSubpkgs[:i]
-			updatePkgImports = len(newSubpkgs) != 0
+			plan.newSubpkgs = plan.newSubpkgs[:i]
+			plan.updatePkgImports = len(plan.newSubpkgs) != 0
 		}
 
 		if _, ok := cachedPkgs[root]; ok {
-			if !updatePkgImports {
-				return nil
+			if !plan.updatePkgImports {
+				return nil, nil
 			}
 		}
 
-		isNewPkg = false
+		plan.isNewPkg = false
 		if !opts.Update {
-			if !updatePkgImports {
+			if !plan.updatePkgImports {
 				if verbose {
 					fmt.Printf("pkg (%s): already in manifest with version: (%s)\n", root, existing)
 				}
-				return nil
+				return nil, nil
 			}
-			performImport = false
+			plan.performImport = false
 		}
-		if version != "" && version == existing.Version {
-			if !updatePkgImports {
+		if plan.version != "" && plan.version == existing.Version {
+			if !plan.updatePkgImports {
 				if verbose {
 					fmt.Printf("pkg (%s): already up to date\n", root)
 				}
-				return nil
+				return nil, nil
+			}
+			plan.performImport = false
+		}
+
+		if !plan.performImport && hasPrunedSubpkgs(existing, plan.newSubpkgs) {
+			plan.performImport, plan.unprune = true, true
+			plan.version, plan.versionRequired, plan.candidates = existing.CommitHash, true, nil
+			plan.fromLock, plan.resolved = false, false
+		}
+
+		// non-git repos are not cached, so offline their vendored version is kept, if it's the requested one.
+		keepVendored := plan.performImport && !plan.unprune && offline && existing.VCS != "" && !plan.isLocal &&
+			vendoredVersionMatches(root, existing, plan.version)
+		if keepVendored {
+			if verbose {
+				fmt.Printf("pkg (%s): offline mode, keeping vendored %s repo at (%s)\n", root, existing.VCS, existing)
 			}
-			performImport = false
+			plan.performImport, plan.resolved = false, false
 		}
 
-		if opts.Update {
+		if !plan.performImport {
+			hash, err := pkgHash(root, existing.Pruned)
+			if err != nil {
+				return nil, fmt.Errorf("pkg (%s): cannot compute hash: %v", root, err)
+			}
+			if existing.Hash == "" {
+				existing.Hash = hash
+			} else if hash != existing.Hash {
+				return nil, fmt.Errorf("pkg (%s): vendored files were modified, manifest hash %s, vendor hash %s", root, existing.Hash, hash)
+			}
+		}
+		if (opts.Update || plan.unprune) && !keepVendored {
 			if err := os.RemoveAll(fmt.Sprintf("%s/%s", manifest.VendorPath, root)); err != nil {
-				return fmt.Errorf("pkg (%s): fail remove existing pkg", root)
+				return nil, fmt.Errorf("pkg (%s): fail remove existing pkg", root)
 			}
 		}
-		info = existing
+		plan.info = existing
 	}
+	plan.rootPkg = rootPkg
 
-	if performImport {
-		root, pkgInfo, _, err := doImport(ctx, rootPkg, version, isLocal, opts.Update, true, verbose, versionRequired)
+	return plan, nil
+}
+
+// vendoredVersionMatches checks whether manifest pkg is vendored and its version satisfies requested one,
+// empty version is satisfied by any.
+func vendoredVersionMatches(pkg string, info Package, version string) bool {
+	if _, err := os.Stat(fmt.Sprintf("%s/%s", manifest.VendorPath, pkg)); err != nil {
+		return false
+	}
+	if version == "" || versionMatches(info, version) {
+		return true
+	}
+
+	return isSemverRange(version) && constraintAllows(version, info.Version)
+}
+
+// finishImport updates manifest with imported pkg, returns pkg dependencies to import.
+func finishImport(ctx context.Context, plan *importPlan, verbose bool) ([]importTask, error) {
+	var (
+		opts     = plan.task.opts
+		isUpdate = opts.Update || opts.UpdateDeps
+		rootPkg  = plan.rootPkg
+		info     = plan.info
+	)
+
+	if plan.performImport {
+		root, pkgInfo, err := plan.src.Root, plan.fetchedInfo, plan.fetchErr
 		if root != "" && ctxCancelled(ctx) && !isUpdate {
 			newPkgs = append(newPkgs, root)
 		}
 		if err != nil {
-			return err
+			return nil, err
+		}
+		if !plan.isNewPkg && info.Hash != "" && pkgInfo.CommitHash == info.CommitHash && pkgInfo.Hash != info.Hash {
+			return nil, fmt.Errorf("pkg (%s): content of commit %s does not match manifest hash %s, got %s", root, pkgInfo.CommitHash, info.Hash, pkgInfo.Hash)
 		}
 
-		if !isNewPkg {
+		if !plan.isNewPkg {
 			subpkgs, err := getPkgSubpackages(root, fmt.Sprintf("%s/%s", manifest.VendorPath, rootPkg), verbose)
 			if err != nil {
-				return err
+				return nil, err
 			}
 
 			subpkgsMap := make(map[string]struct{})
@@ -178,7 +425,8 @@ func importPackage(ctx context.Context, pkg string, opts ImportOptions, verbose
 					}
 				}
 				if len(msgs) != 0 {
-					return fmt.Errorf("%s. You should consider updating these packages first to solve dependency conflicts", strings.Join(msgs, "; "))
+					sort.Strings(msgs)
+					return nil, fmt.Errorf("%s. You should consider updating these packages first to solve dependency conflicts", strings.Join(msgs, "; "))
 				}
 			}
 
@@ -186,6 +434,27 @@ func importPackage(ctx context.Context, pkg string, opts ImportOptions, verbose
 				delete(info.Subpackages, deprecated)
 			}
 			pkgInfo.Subpackages = info.Subpackages
+
+			// subpackages stay pruned at the same commit, unless they are imported again.
+			if pkgInfo.CommitHash == info.CommitHash {
+				pkgInfo.Pruned = prunedExcept(info.Pruned, plan.newSubpkgs)
+			}
+			if plan.unprune {
+				pkgInfo.Version = info.Version
+			}
+			if len(info.Pruned) != 0 {
+				if err := removePrunedFiles(root, pkgInfo.Pruned); err != nil {
+					return nil, fmt.Errorf("pkg (%s): cannot remove pruned subpackages: %v", root, err)
+				}
+				if pkgInfo.Hash, err = pkgHash(root, pkgInfo.Pruned); err != nil {
+					return nil, fmt.Errorf("pkg (%s): cannot compute hash: %v", root, err)
+				}
+			}
+		}
+		if plan.fromLock {
+			if req, ok := requestForCommit(root, pkgInfo.CommitHash); ok && req.Tag != "" {
+				pkgInfo.Version = req.Tag
+			}
 		}
 		rootPkg = root
 		info = pkgInfo
@@ -194,17 +463,17 @@ func importPackage(ctx context.Context, pkg string, opts ImportOptions, verbose
 			fmt.Println(info)
 		}
 	}
-	if !isUpdate && isNewPkg {
+	if !isUpdate && plan.isNewPkg {
 		newPkgs = append(newPkgs, rootPkg)
 	}
 
 	if ctxCancelled(ctx) {
-		return ctx.Err()
+		return nil, ctx.Err()
 	}
 
-	imports, localSubpkgs, depsMap, err := getPkgImports(rootPkg, info, newSubpkgs, fmt.Sprintf("%s/%s", manifest.VendorPath, rootPkg), isNewPkg, opts.FetchAll, false, verbose)
+	imports, localSubpkgs, depsMap, err := getPkgImports(rootPkg, info, plan.newSubpkgs, fmt.Sprintf("%s/%s", manifest.VendorPath, rootPkg), plan.isNewPkg, plan.fetchAll, false, verbose)
 	if err != nil {
-		return fmt.Errorf("pkg (%s): failed get imports: %v", pkg, err)
+		return nil, fmt.Errorf("pkg (%s): failed get imports: %v", plan.task.pkg, err)
 	}
 
 	for _, i := range imports {
@@ -215,92 +484,219 @@ func importPackage(ctx context.Context, pkg string, opts ImportOptions, verbose
 			info.Subpackages[subpkg] = struct{}{}
 		}
 	}
+	if includePkgTests() {
+		dirs := []string{fmt.Sprintf("%s/%s", manifest.VendorPath, rootPkg)}
+		if !plan.fetchAll {
+			dirs = dirs[:0]
+			for _, subpkg := range localSubpkgs {
+				dirs = append(dirs, fmt.Sprintf("%s/%s", manifest.VendorPath, subpkg))
+			}
+		}
+		testImports, testDepsMap, err := getPkgTestImports(rootPkg, dirs, plan.fetchAll, false, verbose)
+		if err != nil {
+			return nil, fmt.Errorf("pkg (%s): failed get test imports: %v", plan.task.pkg, err)
+		}
+		if info.TestDeps == nil {
+			info.TestDeps = make(map[string]struct{})
+		}
+		for _, i := range testImports {
+			info.TestDeps[i] = struct{}{}
+		}
+		mergeDeps(depsMap, testDepsMap)
+	}
 
 	manifest.Packages[rootPkg] = info
 	cachedPkgs[rootPkg] = struct{}{}
-	if isLocal {
+	if plan.resolved && !plan.isLocal {
+		addResolvedPkg(rootPkg, plan)
+	}
+	if plan.isLocal {
 		if _, ok := manifest.LocalPackages[rootPkg]; !ok {
 			manifest.LocalPackages[rootPkg] = struct{}{}
 		}
 	}
 
-	for importRoot, importSubpkgs := range depsMap {
-		importOpts := ImportOptions{
-			Update:      opts.UpdateDeps,
-			UpdateDeps:  opts.UpdateDeps,
-			Subpackages: importSubpkgs,
-		}
-
-		if err := importPackage(ctx, importRoot, importOpts, verbose); err != nil {
-			return err
-		}
+	tasks := make([]importTask, 0, len(depsMap))
+	for _, importRoot := range sortedKeys(depsMap) {
+		tasks = append(tasks, importTask{
+			pkg: importRoot,
+			opts: ImportOptions{
+				Update:      opts.UpdateDeps,
+				UpdateDeps:  opts.UpdateDeps,
+				Subpackages: depsMap[importRoot],
+			},
+		})
 	}
 
-	return nil
+	return tasks, nil
 }
 
 // doImport imports package only. Returns pkg root, pkg dependencies and an error if occur.
-func doImport(ctx context.Context, pkg, version string, isLocal, update, fetchDeps, verbose, versionRequired bool) (root string, info Package, deps []string, pkgErr error) {
-	var commit, commitVersion string
-	if isLocal {
-		if verbose {
-			fmt.Printf("pkg %s is local, clonning...\n", pkg)
+func doImport(ctx context.Context, pkg, version, source, sourceVCS string, isLocal, update, fetchDeps, verbose, versionRequired bool) (root string, info Package, deps []string, pkgErr error) {
+	src, err := resolveRepo(pkg, source, sourceVCS, isLocal)
+	if err != nil {
+		pkgErr = err
+		return
+	}
+	root = src.Root
+	if verbose && !isLocal {
+		fmt.Println(root, version)
+	}
+
+	info, deps, pkgErr = fetchRepo(ctx, src, version, nil, fetchDeps, verbose, versionRequired)
+	return
+}
+
+// resolveRepo detects pkg root and repository, pkg is fetched from source if it's set.
+func resolveRepo(pkg, source, sourceVCS string, isLocal bool) (repoSource, error) {
+	if !isLocal {
+		if source != "" {
+			return resolveSource(pkg, source, sourceVCS)
 		}
-		dirPath := fmt.Sprintf("%s/src/%s", os.Getenv("GOPATH"), pkg)
-		if _, err := os.Stat(dirPath); err != nil {
-			if os.IsNotExist(err) {
-				pkgErr = fmt.Errorf("cannot detect vcs version of package %s: %v", pkg, err)
-				return
+		if offline {
+			// non-git repos are never cached.
+			if info, _, exists := manifest.PkgExists(pkg); exists && info.VCS != "" {
+				return repoSource{}, fmt.Errorf("pkg (%s): offline mode fetches only git repos, %s repo has to be vendored at the requested version", pkg, info.VCS)
 			}
-			pkgErr = fmt.Errorf("cannot get info about local pkg %s: %v", dirPath, err)
-			return
+			root, ok := getCachedRoot(pkg)
+			if !ok {
+				return repoSource{}, notCachedError{pkg: pkg}
+			}
+			return repoSource{Root: root, VCS: "git"}, nil
 		}
 
-		rootPath := fmt.Sprintf("%s/src/", os.Getenv("GOPATH"))
-		vcs, rootPkg, err := vcs.FromDir(dirPath, rootPath)
+		repoRoot, err := repoRootForImportPath(pkg, false)
 		if err != nil {
-			pkgErr = fmt.Errorf("cannot detect vcs version of package %s: %v", pkg, err)
-			return
+			return repoSource{}, fmt.Errorf("pkg (%s): cannot detect pkg repository: %v", pkg, err)
 		}
-		root = rootPkg
-		if vcs.Cmd != "git" {
-			pkgErr = fmt.Errorf("pkg (%s): ven supports only git repos", pkg)
-			return
+		if _, ok := vcsDrivers[repoRoot.VCS.Cmd]; !ok {
+			return repoSource{}, fmt.Errorf("pkg (%s): ven does not support %s repos", pkg, repoRoot.VCS.Name)
+		}
+
+		repo := repoRoot.Repo
+		if repoRoot.VCS.Cmd == "git" {
+			repo += ".git"
+		}
+
+		return repoSource{Root: repoRoot.Root, Repo: repo, VCS: repoRoot.VCS.Cmd}, nil
+	}
+
+	dirPath := fmt.Sprintf("%s/src/%s", os.Getenv("GOPATH"), pkg)
+	if _, err := os.Stat(dirPath); err != nil {
+		if os.IsNotExist(err) {
+			return repoSource{}, fmt.Errorf("cannot detect vcs version of package %s: %v", pkg, err)
 		}
-		pkg = root
+		return repoSource{}, fmt.Errorf("cannot get info about local pkg %s: %v", dirPath, err)
+	}
 
-		pkgPath := fmt.Sprintf("%s/src/%s", os.Getenv("GOPATH"), pkg)
-		commit, commitVersion, err = cloneLocalPkg(ctx, pkg, version, pkgPath, fmt.Sprintf("%s/%s", manifest.VendorPath, pkg))
+	rootPath := fmt.Sprintf("%s/src/", os.Getenv("GOPATH"))
+	vcs, root, err := vcs.FromDir(dirPath, rootPath)
+	if err != nil {
+		return repoSource{}, fmt.Errorf("cannot detect vcs version of package %s: %v", pkg, err)
+	}
+	if _, ok := vcsDrivers[vcs.Cmd]; !ok {
+		return repoSource{}, fmt.Errorf("pkg (%s): ven does not support %s repos", pkg, vcs.Name)
+	}
+
+	return repoSource{
+		Root:  root,
+		Repo:  fmt.Sprintf("%s/src/%s", os.Getenv("GOPATH"), root),
+		VCS:   vcs.Cmd,
+		Local: true,
+	}, nil
+}
+
+// resolveSource resolves repository of pkg fetched from source: a repository url or an import path of a fork.
+// Source repository is cached on its own, so a fork never mixes with the original repo.
+func resolveSource(pkg, source, sourceVCS string) (repoSource, error) {
+	root := getPkgRoot(pkg)
+	if _, r, exists := manifest.PkgExists(pkg); exists {
+		root = r
+	}
+	src := repoSource{Root: root, Source: source, VCS: vcsName(sourceVCS)}
+
+	switch {
+	case isRepoURL(source):
+		src.Repo, src.cacheRoot = source, urlCacheRoot(source)
+		if offline {
+			if path, err := cachedRepoPath(src.cacheRoot); err != nil || !isMirror(path) {
+				return repoSource{}, notCachedError{pkg: pkg}
+			}
+		}
+	case offline:
+		cacheRoot, ok := getCachedRoot(source)
+		if !ok {
+			return repoSource{}, notCachedError{pkg: pkg}
+		}
+		src.cacheRoot = cacheRoot
+	default:
+		repoRoot, err := repoRootForImportPath(source, false)
+		if err != nil {
+			return repoSource{}, fmt.Errorf("pkg (%s): cannot detect repository of source %s: %v", pkg, source, err)
+		}
+		src.VCS, src.Repo, src.cacheRoot = repoRoot.VCS.Cmd, repoRoot.Repo, repoRoot.Root
+		if src.VCS == "git" {
+			src.Repo += ".git"
+		}
+	}
+	if _, ok := vcsDrivers[src.VCS]; !ok {
+		return repoSource{}, fmt.Errorf("pkg (%s): ven does not support %s repos", pkg, src.VCS)
+	}
+	if offline && src.VCS != "git" {
+		return repoSource{}, fmt.Errorf("pkg (%s): offline mode fetches only git repos, %s repo has to be vendored at the requested version", pkg, src.VCS)
+	}
+
+	return src, nil
+}
+
+// fetchRepo clones pkg repository into vendor, checks out the version and filters non go files.
+// If candidates are set, the newest candidate revision is checked out instead of version.
+func fetchRepo(ctx context.Context, src repoSource, version string, candidates []string, fetchDeps, verbose, versionRequired bool) (info Package, deps []string, pkgErr error) {
+	var (
+		commit, commitVersion string
+		err                   error
+		pkg                   = src.Root
+		vendorPath            = fmt.Sprintf("%s/%s", manifest.VendorPath, pkg)
+		driver                = vcsDrivers[src.VCS]
+	)
+	if driver == nil {
+		pkgErr = fmt.Errorf("pkg (%s): ven does not support %s repos", pkg, src.VCS)
+		return
+	}
+	if src.Local {
+		if verbose {
+			fmt.Printf("pkg %s is local, clonning...\n", pkg)
+		}
+		commit, commitVersion, err = cloneLocalPkg(ctx, driver, pkg, version, src.Repo, vendorPath)
 		if err != nil {
 			pkgErr = fmt.Errorf("cannot clone local package %s: %v", pkg, err)
 			return
 		}
 	} else {
-		repoRoot, err := vcs.RepoRootForImportPath(pkg, false)
+		commit, commitVersion, err = cloneRepo(ctx, driver, src.CacheRoot(), version, src.Repo, vendorPath, versionRequired)
 		if err != nil {
-			pkgErr = fmt.Errorf("pkg (%s): cannot detect pkg repository: %v", pkg, err)
+			pkgErr = fmt.Errorf("pkg (%s): cannot clone repo: %v", pkg, err)
 			return
 		}
-		if repoRoot.VCS.Cmd != "git" {
-			pkgErr = fmt.Errorf("pkg (%s): ven supports only git repos", pkg)
+	}
+	if len(candidates) != 0 {
+		version, err = newestRevision(ctx, driver, vendorPath, candidates)
+		if err != nil {
+			pkgErr = fmt.Errorf("pkg (%s): cannot pick the newest of requested versions: %v", pkg, err)
 			return
 		}
-
-		pkg = repoRoot.Root
-		root = pkg
 		if verbose {
-			fmt.Println(pkg, version)
+			fmt.Printf("pkg (%s): picked %s of requested versions %s\n", pkg, version, strings.Join(candidates, ", "))
 		}
-		commit, commitVersion, err = cloneRepo(ctx, pkg, version, repoRoot.Repo+".git", fmt.Sprintf("%s/%s", manifest.VendorPath, pkg), versionRequired)
+		commit, commitVersion, err = checkoutRepo(ctx, driver, version, vendorPath, true)
 		if err != nil {
-			pkgErr = fmt.Errorf("pkg (%s): cannot clone repo: %v", pkg, err)
+			pkgErr = fmt.Errorf("pkg (%s): cannot checkout %s: %v", pkg, version, err)
 			return
 		}
 	}
-	vendorPath := fmt.Sprintf("%s/%s", manifest.VendorPath, pkg)
 
 	if fetchDeps {
-		pkgs, err := getPkgImportsFromPopularVendorTools(pkg, vendorPath, verbose)
+		pkgs, err := getPkgImportsFromPopularVendorTools(pkg, vendorPath, false, verbose)
 		if err != nil {
 			if verbose {
 				fmt.Println(err)
@@ -308,145 +704,122 @@ func doImport(ctx context.Context, pkg, version string, isLocal, update, fetchDe
 		}
 		deps = pkgs
 	}
-	if err := filterNonGoFiles(vendorPath); err != nil {
+	if err := filterPkgFiles(pkg, vendorPath); err != nil {
 		pkgErr = fmt.Errorf("failed filter pkg (%s): %v", pkg, err)
 		return
 	}
 
+	// a commit pinned by lock file is described by a tag instead.
 	pkgVersion := version
-	if version == "" {
+	if version == "" || isSemverRange(version) || strings.HasPrefix(commit, version) {
 		pkgVersion = commitVersion
 	}
 
+	hash, err := pkgHash(pkg, manifest.Packages[pkg].Pruned)
+	if err != nil {
+		pkgErr = fmt.Errorf("pkg (%s): cannot compute hash: %v", pkg, err)
+		return
+	}
+
+	pkgVCS := src.VCS
+	if pkgVCS == "git" {
+		pkgVCS = ""
+	}
+
 	info = Package{
 		CommitHash:  commit,
 		Version:     pkgVersion,
+		Hash:        hash,
+		VCS:         pkgVCS,
+		Source:      src.Source,
 		Deps:        make(map[string]struct{}),
 		Subpackages: make(map[string]struct{}),
+		TestDeps:    make(map[string]struct{}),
 	}
 
 	return
 }
 
-func cloneLocalPkg(ctx context.Context, pkg, version, pkgPath, destPath string) (string, string, error) {
+func cloneLocalPkg(ctx context.Context, driver vcsDriver, pkg, version, pkgPath, destPath string) (string, string, error) {
 	if err := copyDir(ctx, pkgPath, destPath); err != nil {
 		return "", "", err
 	}
 
-	return checkoutRepo(ctx, version, destPath, true)
+	return checkoutRepo(ctx, driver, version, destPath, true)
 }
 
 // cloneRepo clones repo with specific reference
-func cloneRepo(ctx context.Context, pkg, version, repo, dir string, versionRequired bool) (string, string, error) {
-	var outb, errb bytes.Buffer
-
-	cmd := exec.CommandContext(ctx, "git", "clone", repo, dir)
-	cmd.Stdout = &outb
-	cmd.Stderr = &errb
-
-	if err := cmd.Run(); err != nil {
-		os.Stdout.Write(outb.Bytes())
-		os.Stderr.Write(errb.Bytes())
+func cloneRepo(ctx context.Context, driver vcsDriver, pkg, version, repo, dir string, versionRequired bool) (string, string, error) {
+	if err := driver.Clone(ctx, pkg, repo, dir); err != nil {
 		return "", "", err
 	}
 
-	return checkoutRepo(ctx, version, dir, versionRequired)
+	return checkoutRepo(ctx, driver, version, dir, versionRequired)
 }
 
-func checkoutRepo(ctx context.Context, version, repoPath string, versionRequired bool) (string, string, error) {
-	var outb, errb bytes.Buffer
+// checkoutRepo checks out version if set, returns current revision and the latest tag.
+// Semver range version is resolved to the highest satisfying tag, which is returned as the latest tag.
+func checkoutRepo(ctx context.Context, driver vcsDriver, version, repoPath string, versionRequired bool) (string, string, error) {
+	if isSemverRange(version) {
+		tags, err := driver.Tags(ctx, repoPath)
+		if err != nil {
+			return "", "", err
+		}
+		tag, err := resolveSemverRange(version, tags)
+		if err != nil {
+			return "", "", err
+		}
+		if err := driver.Checkout(ctx, repoPath, tag); err != nil {
+			return "", "", err
+		}
+		commit, err := driver.Revision(ctx, repoPath)
+		if err != nil {
+			return "", "", err
+		}
 
+		return commit, tag, nil
+	}
 	if version != "" {
-		cmd := exec.CommandContext(ctx, "git", "-C", repoPath, "checkout", version)
-		cmd.Stdout = &outb
-		cmd.Stderr = &errb
-
-		if err := cmd.Run(); err != nil && versionRequired {
-			os.Stdout.Write(outb.Bytes())
-			os.Stderr.Write(errb.Bytes())
+		if err := driver.Checkout(ctx, repoPath, version); err != nil && versionRequired {
 			return "", "", err
 		}
-
-		outb.Reset()
-		errb.Reset()
 	}
 
-	cmd := exec.CommandContext(ctx, "git", "-C", repoPath, "rev-parse", "HEAD")
-	cmd.Stdout = &outb
-	cmd.Stderr = &errb
-
-	if err := cmd.Run(); err != nil {
-		os.Stdout.Write(outb.Bytes())
-		os.Stderr.Write(errb.Bytes())
+	commit, err := driver.Revision(ctx, repoPath)
+	if err != nil {
 		return "", "", err
 	}
 
-	commit := strings.TrimSpace(outb.String())
-
-	outb.Reset()
-	errb.Reset()
-
-	cmd = exec.CommandContext(ctx, "git", "-C", repoPath, "describe", "--tags", "--abbrev=0")
-	cmd.Stdout = &outb
-	cmd.Stderr = &errb
-
-	if err := cmd.Run(); err != nil {
+	tag, err := driver.Describe(ctx, repoPath)
+	if err != nil {
 		return commit, "", nil
 	}
 
-	return commit, strings.TrimSpace(outb.String()), nil
+	return commit, tag, nil
 }
 
-func filterNonGoFiles(dir string) error {
-	var dirs []string
+// runGit runs git command in a repo dir and returns its output.
+func runGit(ctx context.Context, dir string, args ...string) (string, error) {
+	if dir != "" {
+		args = append([]string{"-C", dir}, args...)
+	}
 
-	err := filepath.Walk(dir, func(path string, f os.FileInfo, err error) error {
-		if err != nil || f == nil {
-			if os.IsNotExist(err) {
-				return nil
-			}
-			if _, err = os.Stat(path); os.IsNotExist(err) {
-				return nil
-			}
-			return err
-		}
-		if f.IsDir() {
-			if dirIsExcluded(path) {
-				if err := os.RemoveAll("./" + path); err != nil && !os.IsNotExist(err) {
-					return fmt.Errorf("fail delete (%s): %v", path, err)
-				}
-				return nil
-			}
-			dirs = append(dirs, path)
+	return runCmd(ctx, "", "git", args...)
+}
 
-			return nil
-		}
-		if !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
-			// go package may use cgo or assembler files.
-			excludeExt := []string{"s", "S", "asm", "h", "o", "c", "cc"}
-			for _, ext := range excludeExt {
-				if strings.HasSuffix(path, "."+ext) {
-					return nil
-				}
-			}
-			if err := os.Remove(path); err != nil {
-				return fmt.Errorf("fail delete (%s): %v", path, err)
-			}
-		}
-		return nil
-	})
+// runCmd runs command in dir and returns its output.
+func runCmd(ctx context.Context, dir, name string, args ...string) (string, error) {
+	var outb, errb bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = dir
+	cmd.Stdout = &outb
+	cmd.Stderr = &errb
 
-	for _, d := range dirs {
-		files, err := ioutil.ReadDir(d)
-		if err != nil {
-			continue
-		}
-		if len(files) == 0 {
-			os.Remove(d)
-		}
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%v: %s", err, strings.TrimSpace(errb.String()))
 	}
 
-	return err
+	return outb.String(), nil
 }
 
 func dirIsExcluded(path string) bool {
@@ -461,41 +834,73 @@ func dirIsExcluded(path string) bool {
 	for excl := range manifest.ExcludeDir {
 		excludes = append(excludes, excl)
 	}
+	// project dirs are scanned relative to its root, so a top level dir has no leading slash, like tools.
 	for _, excl := range excludes {
-		if strings.HasSuffix(path, "/"+excl) || strings.Contains("/"+path, "/"+excl+"/") {
+		if strings.HasSuffix("/"+path, "/"+excl) || strings.Contains("/"+path, "/"+excl+"/") {
 			return true
 		}
 	}
 	return false
 }
 
-func getPkgImportsFromPopularVendorTools(pkg, dir string, verbose bool) ([]string, error) {
+// getPkgImportsFromPopularVendorTools caches versions pinned by lock file of pkg in dir.
+// Ven manifest of the project itself is skipped, since it's already used directly.
+func getPkgImportsFromPopularVendorTools(pkg, dir string, isProject, verbose bool) ([]string, error) {
 	// first try to parse deps from popular vendoring tools.
-	for pName, p := range parse.Parsers {
-		if !p.Check(dir) {
+	for _, pName := range parse.Names() {
+		if isProject && pName == "ven" {
+			continue
+		}
+		if !parse.Parsers[pName].Check(dir) {
 			continue
 		}
 
-		pkgs, err := p.Parse(dir)
-		if err != nil {
-			return nil, fmt.Errorf("pkg (%s): failed parse (%s) config file: %v", pkg, pName, err)
+		return cacheLockFile(pkg, dir, pName, false, verbose)
+	}
+
+	return nil, nil
+}
+
+// cacheLockFile caches versions pinned by pName lock file of pkg in dir.
+// Cached versions are hints used when no other version is requested, unless pin is set:
+// then they are project pins, which win over other requests.
+func cacheLockFile(pkg, dir, pName string, pin, verbose bool) ([]string, error) {
+	pkgs, err := parse.Parsers[pName].Parse(dir)
+	if err != nil {
+		return nil, fmt.Errorf("pkg (%s): failed parse (%s) config file: %v", pkg, pName, err)
+	}
+	if verbose {
+		fmt.Printf("pkg (%s): detected %s vendoring\n", pkg, pName)
+	}
+
+	imports := make([]string, 0, len(pkgs))
+	for _, subPkg := range pkgs {
+		// some tools pin subpackages, versions are cached per root pkg.
+		root, subpkgs := getPkgRoot(subPkg.Name), subPkg.Subpackages
+		if root != subPkg.Name {
+			subpkgs = append(subpkgs, subPkg.Name)
 		}
-		if verbose {
-			fmt.Printf("pkg (%s): detected %s vendoring\n", pkg, pName)
+		req := versionRequest{
+			Version:     subPkg.CommitHash,
+			Tag:         subPkg.Version,
+			Source:      subPkg.Source,
+			VCS:         subPkg.VCS,
+			Requester:   pkg,
+			Tool:        pName,
+			Subpackages: subpkgs,
+			Project:     pin,
 		}
-
-		imports := make([]string, 0, len(pkgs))
-		for _, subPkg := range pkgs {
-			if _, exist := cachedConstraints[subPkg.Name]; !exist {
-				cachedConstraints[subPkg.Name] = subPkg.CommitHash
-			}
-			imports = append(imports, subPkg.Name+"@"+subPkg.CommitHash)
+		// a constraint other than a semver range names an exact revision.
+		if subPkg.Constraint != "" && isSemverRange(subPkg.Constraint) {
+			req.Constraint = subPkg.Constraint
+		} else if subPkg.Constraint != "" {
+			req.Version = subPkg.Constraint
 		}
-
-		return imports, nil
+		addCachedConstraint(root, req)
+		imports = append(imports, subPkg.Name+"@"+req.String())
 	}
 
-	return nil, nil
+	return imports, nil
 }
 
 // go list -f '{{join .Deps "\n"}}' |  xargs go list -f '{{if not .Standard}}{{.ImportPath}}{{end}}'
@@ -504,7 +909,7 @@ func getPkgImports(pkg string, info Package, subpkgs []string, dir string, isNew
 
 	var localPkgs []string
 	if fetchAll {
-		locals, err := walkImports(pkg, info, dir, fetchAll, importsMap, parseMain, verbose)
+		locals, err := walkImports(pkg, info, dir, fetchAll, false, importsMap, parseMain, verbose)
 		if err != nil {
 			return nil, nil, nil, err
 		}
@@ -530,7 +935,7 @@ func getPkgImports(pkg string, info Package, subpkgs []string, dir string, isNew
 		for len(dirsToWalk) != 0 {
 			nextDirs := make([]string, 0, 4)
 			for _, dir := range dirsToWalk {
-				locals, err := walkImports(pkg, info, manifest.VendorPath+"/"+dir, fetchAll, importsMap, parseMain, verbose)
+				locals, err := walkImports(pkg, info, manifest.VendorPath+"/"+dir, fetchAll, false, importsMap, parseMain, verbose)
 				if err != nil {
 					return nil, nil, nil, err
 				}
@@ -551,6 +956,13 @@ func getPkgImports(pkg string, info Package, subpkgs []string, dir string, isNew
 		}
 	}
 
+	imports, rootPkgsMap := groupImports(importsMap)
+
+	return imports, localPkgs, rootPkgsMap, nil
+}
+
+// groupImports returns sorted imports and imports grouped by root packages.
+func groupImports(importsMap map[string]struct{}) ([]string, map[string][]string) {
 	imports := make([]string, 0, len(importsMap))
 	for i := range importsMap {
 		imports = append(imports, i)
@@ -570,7 +982,18 @@ func getPkgImports(pkg string, info Package, subpkgs []string, dir string, isNew
 		rootPkgsMap[rootPkg] = []string{i}
 	}
 
-	return imports, localPkgs, rootPkgsMap, nil
+	return imports, rootPkgsMap
+}
+
+// sortedKeys returns sorted roots of a deps map, so packages are always imported in the same order.
+func sortedKeys(depsMap map[string][]string) []string {
+	keys := make([]string, 0, len(depsMap))
+	for key := range depsMap {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
 }
 
 func getPkgSubpackages(pkg, dir string, verbose bool) ([]string, error) {
@@ -593,7 +1016,8 @@ func getPkgSubpackages(pkg, dir string, verbose bool) ([]string, error) {
 	return subpkgs, err
 }
 
-func walkImports(pkg string, info Package, dir string, scanAll bool, importsMap map[string]struct{}, parseMain, verbose bool) ([]string, error) {
+// walkImports scans imports of go files in dir, or of _test.go files only if tests is set.
+func walkImports(pkg string, info Package, dir string, scanAll, tests bool, importsMap map[string]struct{}, parseMain, verbose bool) ([]string, error) {
 	fset := token.NewFileSet()
 
 	locals := make([]string, 0, 4)
@@ -605,7 +1029,6 @@ func walkImports(pkg string, info Package, dir string, scanAll bool, importsMap
 		}
 		return nil, err
 	}
-FilesLoop:
 	for _, f := range files {
 		path := path.Join(dir, f.Name())
 		if f.IsDir() {
@@ -613,7 +1036,7 @@ FilesLoop:
 				continue
 			}
 			if scanAll {
-				localPkgs, err := walkImports(pkg, info, path, scanAll, importsMap, parseMain, verbose)
+				localPkgs, err := walkImports(pkg, info, path, scanAll, tests, importsMap, parseMain, verbose)
 				if err != nil {
 					return nil, err
 				}
@@ -621,7 +1044,7 @@ FilesLoop:
 			}
 			continue
 		}
-		if !strings.HasSuffix(f.Name(), ".go") {
+		if !strings.HasSuffix(f.Name(), ".go") || strings.HasSuffix(f.Name(), "_test.go") != tests {
 			continue
 		}
 
@@ -636,93 +1059,111 @@ FilesLoop:
 		if len(parsedFile.Imports) == 0 {
 			continue
 		}
-		if !parseMain && parsedFile.Name != nil && parsedFile.Name.Name == "main" {
+		scanned, err := importsScanned(path, parsedFile, parseMain, verbose)
+		if err != nil {
+			return nil, err
+		}
+		if !scanned {
 			continue
 		}
-		if len(manifest.ExcludeBuild) != 0 {
-			for excl := range manifest.ExcludeBuild {
-				if strings.HasSuffix(f.Name(), "_"+excl+".go") {
-					if verbose {
-						fmt.Printf("file (%s) with build tag (%s) excluded\n", f.Name(), excl)
-					}
-					continue FilesLoop
-				}
-			}
-
-			file, err := os.Open(path)
-			if err != nil {
-				return nil, fmt.Errorf("fail open file %s: %v", path, err)
-			}
-			scanner := bufio.NewScanner(file)
-			var line string
 
-		ScanLoop:
-			for scanner.Scan() {
-				line = scanner.Text()
-				if strings.HasPrefix(line, "package") {
-					break
-				}
-				buildIndex := strings.Index(line, "+build")
-				if !strings.HasPrefix(line, "//") || buildIndex == -1 || buildIndex+len("+build ") >= len(line) {
-					continue
-				}
+		for _, i := range parsedFile.Imports {
+			iVal := strings.Replace(i.Path.Value, "\"", "", -1)
 
-				for _, build := range strings.Split(line[buildIndex+len("+build "):], " ") {
-					if _, ok := manifest.ExcludeBuild[build]; !ok {
-						continue ScanLoop
-					}
-				}
-				if verbose {
-					fmt.Printf("file (%s) with build tag(s) (%s) excluded\n", path, line[buildIndex+len("+build "):])
+			switch classifyImport(pkg, iVal) {
+			case importLocal:
+				locals = append(locals, iVal)
+			case importExternal:
+				if _, exists := importsMap[iVal]; !exists {
+					importsMap[iVal] = struct{}{}
 				}
-				file.Close()
-				continue FilesLoop
-			}
-			file.Close()
-			if err := scanner.Err(); err != nil {
-				return nil, fmt.Errorf("fail read file %s: %v", path, err)
 			}
 		}
+	}
 
-		for _, i := range parsedFile.Imports {
-			iVal := strings.Replace(i.Path.Value, "\"", "", -1)
+	return locals, nil
+}
 
-			// from std
-			if !strings.Contains(iVal, "/") {
-				continue
-			}
-			if strings.HasPrefix(iVal, "./") || strings.HasPrefix(iVal, "../") {
-				continue
-			}
-			// not external
-			if !strings.Contains(iVal[:strings.Index(iVal, "/")], ".") {
-				continue
-			}
-			// subpkg of current pkg
-			if pkg != "" && strings.HasPrefix(iVal, pkg) {
-				locals = append(locals, iVal)
-				continue
-			}
-			pkgRoot := pkg
-			parts := strings.Split(pkg, "/")
-			// for the case if we call vendor not from repo root, but for instance from ./cmd,
-			// that has cmd specific deps, we don't want to import project in vendor.
-			if len(parts) > 3 {
-				pkgRoot = strings.Join(parts[:3], "/")
-			}
+// importsScanned checks whether imports of parsed go file at path are scanned:
+// files of main packages are scanned only if parseMain is set, files excluded by build constraints never are.
+func importsScanned(path string, file *ast.File, parseMain, verbose bool) (bool, error) {
+	if !parseMain && file.Name != nil && file.Name.Name == "main" {
+		return false, nil
+	}
+	excluded, err := fileIsExcluded(path, verbose)
+	if err != nil {
+		return false, err
+	}
 
-			if pkg != "" && (strings.HasPrefix(iVal, pkg) || strings.HasPrefix(iVal, pkgRoot)) {
-				locals = append(locals, iVal)
-				continue
-			}
+	return !excluded, nil
+}
 
-			if _, exists := importsMap[iVal]; !exists {
-				importsMap[iVal] = struct{}{}
-			}
+// fileIsExcluded checks whether go file is excluded from scan by build tags:
+// if manifest has targets, file is excluded unless it builds for one of them,
+// otherwise file is excluded if its build constraint can't be satisfied without excluded tags.
+func fileIsExcluded(path string, verbose bool) (bool, error) {
+	if len(manifest.ExcludeBuild) == 0 && len(manifest.Targets) == 0 {
+		return false, nil
+	}
+
+	expr, err := fileBuildConstraint(path)
+	if err != nil {
+		return false, err
+	}
+	if expr == nil {
+		return false, nil
+	}
+	if len(manifest.Targets) != 0 {
+		if buildsForTargets(expr, manifest.Targets, manifest.BuildTags, manifest.ExcludeBuild) {
+			return false, nil
 		}
+	} else if buildSatisfiable(expr, manifest.ExcludeBuild) {
+		return false, nil
+	}
+	if verbose {
+		fmt.Printf("file (%s) with build constraint (%s) excluded\n", path, expr)
 	}
 
-	return locals, nil
+	return true, nil
+}
+
+// import kinds returned by classifyImport.
+const (
+	importSkip = iota
+	importLocal
+	importExternal
+)
+
+// classifyImport detects whether import is a subpackage of pkg, an external pkg or needs to be skipped (std, relative).
+func classifyImport(pkg, iVal string) int {
+	// from std
+	if !strings.Contains(iVal, "/") {
+		return importSkip
+	}
+	if strings.HasPrefix(iVal, "./") || strings.HasPrefix(iVal, "../") {
+		return importSkip
+	}
+	// not external
+	if !strings.Contains(iVal[:strings.Index(iVal, "/")], ".") {
+		return importSkip
+	}
+	// subpkg of current pkg
+	if pkg != "" && strings.HasPrefix(iVal, pkg) {
+		return importLocal
+	}
+	pkgRoot := pkg
+	parts := strings.Split(pkg, "/")
+	// for the case if we call vendor not from repo root, but for instance from ./cmd,
+	// that has cmd specific deps, we don't want to import project in vendor.
+	if len(parts) > 3 {
+		pkgRoot = strings.Join(parts[:3], "/")
+	}
+
+	if pkg != "" && (strings.HasPrefix(iVal, pkg) || strings.HasPrefix(iVal, pkgRoot)) {
+		return importLocal
+	}
+
+	return importExternal
 }
 
 // getPkgRoot detects pkg root from commonly used domains.
@@ -14,8 +14,57 @@ func Test_getPkgImports(t *testing.T) {
 		t.Fatalf("getPkgImports() error: %v", err)
 	}
 
-	expected := []string{"github.com/asaskevich/govalidator", "github.com/asaskevich/some", "github.com/asaskevich/wrong", "github.com/golang/dep/internal/gps", "github.com/pelletier/go-toml", "github.com/pkg/errors"}
+	expected := []string{"github.com/asaskevich/darwinamd64", "github.com/asaskevich/gobuildlinux", "github.com/asaskevich/govalidator", "github.com/asaskevich/nocgo", "github.com/asaskevich/notwindows", "github.com/asaskevich/some", "github.com/asaskevich/wrong", "github.com/golang/dep/internal/gps", "github.com/pelletier/go-toml", "github.com/pkg/errors"}
 	if !reflect.DeepEqual(got, expected) {
 		t.Errorf("getPkgImports() = %v, want %v", got, expected)
 	}
 }
+
+func Test_getPkgImportsTargets(t *testing.T) {
+	origManifest := manifest
+	defer func() { manifest = origManifest }()
+	manifest = initManifest()
+	manifest.ExcludeBuild = map[string]struct{}{"integration": {}}
+	manifest.Targets = []buildTarget{{OS: "linux", Arch: "amd64"}, {OS: "darwin", Arch: "arm64"}}
+
+	got, _, _, err := getPkgImports("github.com/pkg/path", Package{}, nil, "testdata", true, true, false, false)
+	if err != nil {
+		t.Fatalf("getPkgImports() error: %v", err)
+	}
+
+	expected := []string{"github.com/asaskevich/gobuildlinux", "github.com/asaskevich/govalidator", "github.com/asaskevich/nocgo", "github.com/asaskevich/wrong", "github.com/golang/dep/internal/gps", "github.com/pelletier/go-toml", "github.com/pkg/errors"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("getPkgImports() = %v, want %v", got, expected)
+	}
+}
+
+func Test_dirIsExcluded(t *testing.T) {
+	origManifest := manifest
+	defer func() { manifest = origManifest }()
+	manifest.ExcludeDir = map[string]struct{}{"tools": {}, "internal/gen": {}}
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"tools", true},
+		{"tools/sub", true},
+		{"cmd/tools", true},
+		{"vendor/github.com/pkg/tools", true},
+		{"mytools", false},
+		{"tools2", false},
+		{"internal/gen", true},
+		{"internal/gen/sub", true},
+		{"gen", false},
+		{"testdata", true},
+		{"pkg/testdata", true},
+		{".git", true},
+		{"_examples", true},
+		{"pkg", false},
+	}
+	for _, tt := range tests {
+		if got := dirIsExcluded(tt.path); got != tt.want {
+			t.Errorf("dirIsExcluded(%s) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
@@ -3,20 +3,81 @@ package main
 import (
 	"context"
 	"errors"
+	"fmt"
+	"sort"
 )
 
-// Install installs vendor dependencies from manifest.
-func Install(ctx context.Context, verbose bool) error {
+// Install installs vendor dependencies from manifest using jobs parallel workers.
+func Install(ctx context.Context, jobs int, verbose bool) error {
 	if vendorExists() {
 		return errors.New("vendor directory already exists")
 	}
 
-	for pkg, info := range manifest.Packages {
-		_, isLocal := manifest.LocalPackages[pkg]
+	pkgs := make([]string, 0, len(manifest.Packages))
+	for pkg := range manifest.Packages {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Strings(pkgs)
+
+	// check cache before any work, so all missing packages are reported at once.
+	if offline {
+		var errs importErrors
+		for _, pkg := range pkgs {
+			if _, isLocal := manifest.LocalPackages[pkg]; isLocal {
+				continue
+			}
+			info := manifest.Packages[pkg]
+			if _, err := resolveRepo(pkg, info.Source, info.VCS, false); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if len(errs) != 0 {
+			return groupNotCached(errs).errOrNil()
+		}
+	}
 
-		if _, _, _, err := doImport(ctx, pkg, info.CommitHash, isLocal, false, false, verbose, true); err != nil {
-			return err
+	pkgErrs := make([]error, len(pkgs))
+	runJobs(jobs, len(pkgs), func(i int) {
+		if ctxCancelled(ctx) {
+			pkgErrs[i] = ctx.Err()
+			return
 		}
+		pkgErrs[i] = installPkg(ctx, pkgs[i], verbose)
+	})
+
+	var errs importErrors
+	for _, err := range pkgErrs {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs.errOrNil()
+}
+
+// installPkg installs manifest pkg and verifies its hash.
+func installPkg(ctx context.Context, pkg string, verbose bool) error {
+	info := manifest.Packages[pkg]
+	_, isLocal := manifest.LocalPackages[pkg]
+
+	_, pkgInfo, _, err := doImport(ctx, pkg, info.CommitHash, info.Source, info.VCS, isLocal, false, false, verbose, true)
+	if err != nil {
+		return err
+	}
+	if pkgInfo.VCS != info.VCS {
+		return fmt.Errorf("pkg (%s): vcs mismatch, manifest vcs %s, repository vcs %s", pkg, vcsName(info.VCS), vcsName(pkgInfo.VCS))
+	}
+	if err := removePrunedFiles(pkg, info.Pruned); err != nil {
+		return fmt.Errorf("pkg (%s): cannot remove pruned subpackages: %v", pkg, err)
+	}
+	if info.Hash == "" {
+		if verbose {
+			fmt.Printf("pkg (%s): no hash in manifest, contents not verified\n", pkg)
+		}
+		return nil
+	}
+	if pkgInfo.Hash != info.Hash {
+		return fmt.Errorf("pkg (%s): hash mismatch, manifest hash %s, installed hash %s (if keep_files settings were changed, update pkg with get -u)", pkg, info.Hash, pkgInfo.Hash)
 	}
 
 	return nil
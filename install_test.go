@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestInstallHashes(t *testing.T) {
+	dir := setupProject(t)
+	addTestRepo(t, dir, "github.com/fx/a", []testCommit{
+		{Files: map[string]string{"a.go": "package a\n", "sub/sub.go": "package sub\n"}, Tag: "v1.0.0"},
+	})
+
+	fetchTestProject(t, "github.com/fx/a")
+	hash := manifest.Packages["github.com/fx/a"].Hash
+	if hash == "" {
+		t.Fatal("Fetch() recorded no hash of github.com/fx/a")
+	}
+	if vendorHash, err := pkgHash("github.com/fx/a", nil); err != nil || vendorHash != hash {
+		t.Fatalf("manifest hash = %s, vendor hash = %s, %v", hash, vendorHash, err)
+	}
+
+	if err := reinstallProject(t, 2); err != nil {
+		t.Fatalf("Install() error: %v", err)
+	}
+	if vendorHash, err := pkgHash("github.com/fx/a", nil); err != nil || vendorHash != hash {
+		t.Fatalf("installed hash = %s, want %s, %v", vendorHash, hash, err)
+	}
+
+	info := manifest.Packages["github.com/fx/a"]
+	info.Hash = "h1:tampered"
+	manifest.Packages["github.com/fx/a"] = info
+	if err := reinstallProject(t, 2); err == nil || !strings.Contains(err.Error(), "hash mismatch") {
+		t.Errorf("Install() with wrong manifest hash error = %v, want hash mismatch", err)
+	}
+	info.Hash = hash
+	manifest.Packages["github.com/fx/a"] = info
+	if err := reinstallProject(t, 2); err != nil {
+		t.Fatalf("Install() error: %v", err)
+	}
+
+	writeFile(t, "vendor/github.com/fx/a/a.go", "package a\n\nconst Modified = true\n")
+	_, err := Get(context.Background(), []string{"github.com/fx/a/sub"}, false, false, false, false)
+	if err == nil || !strings.Contains(err.Error(), "vendored files were modified") {
+		t.Errorf("Get() of modified pkg error = %v, want vendored files were modified", err)
+	}
+}
+
+func TestInstallJobs(t *testing.T) {
+	dir := setupProject(t)
+	var pkgs []string
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		addTestRepo(t, dir, "github.com/fx/"+name, []testCommit{
+			{Files: map[string]string{name + ".go": "package " + name + "\n"}, Tag: "v1.0.0"},
+		})
+		pkgs = append(pkgs, "github.com/fx/"+name)
+	}
+
+	writeMain(t, pkgs...)
+	if err := Fetch(context.Background(), "example.com/proj", false, 4, false); err != nil {
+		t.Fatalf("Fetch() error: %v", err)
+	}
+	wantVendor, err := hashDir("vendor", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := reinstallProject(t, 4); err != nil {
+		t.Fatalf("Install() error: %v", err)
+	}
+	if vendorHash, err := hashDir("vendor", "", nil); err != nil || vendorHash != wantVendor {
+		t.Errorf("installed vendor hash = %s, want %s, %v", vendorHash, wantVendor, err)
+	}
+
+	// errors of all packages are reported, not only the first one.
+	b, d := manifest.Packages["github.com/fx/b"], manifest.Packages["github.com/fx/d"]
+	b.Hash = "h1:tampered"
+	d.CommitHash = "0123456789012345678901234567890123456789"
+	manifest.Packages["github.com/fx/b"], manifest.Packages["github.com/fx/d"] = b, d
+	err = reinstallProject(t, 4)
+	if err == nil {
+		t.Fatal("Install() of broken manifest succeeded")
+	}
+	for _, pkg := range []string{"github.com/fx/b", "github.com/fx/d"} {
+		if !strings.Contains(err.Error(), "pkg ("+pkg+")") {
+			t.Errorf("Install() error does not report %s:\n%v", pkg, err)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func Test_runJobs(t *testing.T) {
+	const n, jobs = 100, 4
+	var (
+		mu      sync.Mutex
+		called  = make(map[int]int)
+		running int32
+		maxRun  int32
+	)
+	runJobs(jobs, n, func(i int) {
+		cur := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			max := atomic.LoadInt32(&maxRun)
+			if cur <= max || atomic.CompareAndSwapInt32(&maxRun, max, cur) {
+				break
+			}
+		}
+
+		mu.Lock()
+		called[i]++
+		mu.Unlock()
+	})
+
+	for i := 0; i < n; i++ {
+		if called[i] != 1 {
+			t.Errorf("fn(%d) called %d times, want 1", i, called[i])
+		}
+	}
+	if maxRun > jobs {
+		t.Errorf("%d jobs run at once, want at most %d", maxRun, jobs)
+	}
+}
+
+// Test_cachedConstraintsJobs fills cached constraints from parallel jobs as fetch workers do, run it with -race.
+func Test_cachedConstraintsJobs(t *testing.T) {
+	origConstraints := cachedConstraints
+	defer func() { cachedConstraints = origConstraints }()
+	cachedConstraints = make(map[string][]versionRequest)
+
+	const n = 50
+	runJobs(8, n, func(i int) {
+		addCachedConstraint("github.com/fx/c", versionRequest{Version: fmt.Sprintf("v1.%d.0", i%10), Requester: fmt.Sprintf("github.com/fx/r%d", i)})
+		addCachedConstraint("github.com/fx/c", versionRequest{Version: fmt.Sprintf("v1.%d.0", i%10), Requester: fmt.Sprintf("github.com/fx/r%d", i), Subpackages: []string{"github.com/fx/c/sub"}})
+		getCachedConstraints("github.com/fx/c")
+	})
+
+	reqs := getCachedConstraints("github.com/fx/c")
+	if len(reqs) != n {
+		t.Fatalf("got %d requests, want %d", len(reqs), n)
+	}
+	for _, r := range reqs {
+		if len(r.Subpackages) != 1 {
+			t.Errorf("request of %s has subpackages %v, want github.com/fx/c/sub", r.Requester, r.Subpackages)
+		}
+	}
+}
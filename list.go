@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// listEntry describes manifest package in a list output.
+type listEntry struct {
+	Name       string `json:"name"`
+	Version    string `json:"version"`
+	CommitHash string `json:"commit_hash"`
+	Local      bool   `json:"local"`
+	Test       bool   `json:"test,omitempty"`
+	Constraint string `json:"constraint,omitempty"`
+}
+
+// treeNode describes package in a dependency tree.
+type treeNode struct {
+	Name       string      `json:"name"`
+	Version    string      `json:"version,omitempty"`
+	CommitHash string      `json:"commit_hash,omitempty"`
+	Missing    bool        `json:"missing,omitempty"`
+	Cycle      bool        `json:"cycle,omitempty"`
+	Deps       []*treeNode `json:"deps,omitempty"`
+}
+
+// List prints manifest packages.
+func List(ctx context.Context, jsonOut bool) error {
+	names := make([]string, 0, len(manifest.Packages))
+	for name := range manifest.Packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	entries := make([]listEntry, 0, len(names))
+	for _, name := range names {
+		info := manifest.Packages[name]
+		_, isLocal := manifest.IsLocalPkg(name)
+		_, constraint, _ := manifest.GetPkgConstraint(name)
+
+		entries = append(entries, listEntry{
+			Name:       name,
+			Version:    info.Version,
+			CommitHash: info.CommitHash,
+			Local:      isLocal,
+			Test:       info.Test,
+			Constraint: constraint,
+		})
+	}
+
+	if jsonOut {
+		return printJSON(entries)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PACKAGE\tVERSION\tCOMMIT\tLOCAL\tTEST\tCONSTRAINT")
+	for _, e := range entries {
+		local, test := "", ""
+		if e.Local {
+			local = "yes"
+		}
+		if e.Test {
+			test = "yes"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", e.Name, e.Version, e.CommitHash, local, test, e.Constraint)
+	}
+
+	return w.Flush()
+}
+
+// Tree prints dependency tree of a project, depth 0 means unlimited.
+func Tree(ctx context.Context, project string, depth int, jsonOut, verbose bool) error {
+	projectDeps, err := getProjectDeps(project, verbose)
+	if err != nil {
+		return err
+	}
+
+	roots := make([]string, 0, len(projectDeps))
+	for root := range projectDeps {
+		roots = append(roots, root)
+	}
+	sort.Strings(roots)
+
+	tree := &treeNode{Name: project}
+	path := make(map[string]struct{})
+	built := make(map[string]builtTreeNode)
+	for _, root := range roots {
+		node, _ := buildTreeNode(root, path, built, 1, depth)
+		tree.Deps = append(tree.Deps, node)
+	}
+
+	if jsonOut {
+		return printJSON(tree)
+	}
+	printTreeNode(tree, 0)
+
+	return nil
+}
+
+// builtTreeNode is a complete tree of pkg dependencies built at level.
+type builtTreeNode struct {
+	node  *treeNode
+	level int
+}
+
+// buildTreeNode builds tree of pkg dependencies, path keeps pkgs of the current branch to detect cycles.
+// Returns whether the tree is complete: has no cycles and is not cut by depth. Complete trees are kept in built
+// and reused for pkg met again at the same or a lower level, so shared deps are not walked over and over.
+func buildTreeNode(pkg string, path map[string]struct{}, built map[string]builtTreeNode, level, depth int) (*treeNode, bool) {
+	if b, ok := built[pkg]; ok && (depth == 0 || level <= b.level) {
+		return b.node, true
+	}
+
+	node := &treeNode{Name: pkg}
+	info, exists := manifest.Packages[pkg]
+	if !exists {
+		node.Missing = true
+		return node, true
+	}
+	node.Version = info.Version
+	node.CommitHash = info.CommitHash
+
+	if _, ok := path[pkg]; ok {
+		node.Cycle = true
+		return node, false
+	}
+	deps := manifest.PkgDepRoots(pkg)
+	if depth > 0 && level >= depth {
+		return node, len(deps) == 0
+	}
+
+	complete := true
+	path[pkg] = struct{}{}
+	for _, dep := range deps {
+		depNode, depComplete := buildTreeNode(dep, path, built, level+1, depth)
+		node.Deps = append(node.Deps, depNode)
+		complete = complete && depComplete
+	}
+	delete(path, pkg)
+
+	if complete {
+		built[pkg] = builtTreeNode{node: node, level: level}
+	}
+
+	return node, complete
+}
+
+func printTreeNode(node *treeNode, level int) {
+	line := strings.Repeat("  ", level) + node.Name
+	if node.Version != "" {
+		line += " " + node.Version
+	}
+	if node.CommitHash != "" {
+		line += " " + shortHash(node.CommitHash)
+	}
+	if node.Missing {
+		line += " (missing)"
+	}
+	if node.Cycle {
+		line += " (cycle)"
+	}
+	fmt.Println(line)
+
+	for _, dep := range node.Deps {
+		printTreeNode(dep, level+1)
+	}
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+
+	return hash
+}
+
+func printJSON(v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("fail marshal json: %v", err)
+	}
+	fmt.Println(string(data))
+
+	return nil
+}
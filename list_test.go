@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// treeString renders tree node like tree command does, on a single line.
+func treeString(node *treeNode) string {
+	s := node.Name
+	if node.Cycle {
+		s += " (cycle)"
+	}
+	if len(node.Deps) == 0 {
+		return s
+	}
+	s += " ["
+	for i, dep := range node.Deps {
+		if i != 0 {
+			s += " "
+		}
+		s += treeString(dep)
+	}
+
+	return s + "]"
+}
+
+func Test_buildTreeNode(t *testing.T) {
+	setupProject(t)
+	pkg := func(deps ...string) Package {
+		info := Package{Deps: make(map[string]struct{})}
+		for _, dep := range deps {
+			info.Deps["github.com/fx/"+dep] = struct{}{}
+		}
+		return info
+	}
+	build := func(depth int, roots ...string) string {
+		path := make(map[string]struct{})
+		built := make(map[string]builtTreeNode)
+		var s string
+		for _, root := range roots {
+			node, _ := buildTreeNode("github.com/fx/"+root, path, built, 1, depth)
+			s += treeString(node) + "\n"
+		}
+		return s
+	}
+
+	manifest.Packages["github.com/fx/a"] = pkg("b")
+	manifest.Packages["github.com/fx/b"] = pkg("a", "c")
+	manifest.Packages["github.com/fx/c"] = pkg("d")
+	manifest.Packages["github.com/fx/d"] = pkg("e")
+	manifest.Packages["github.com/fx/e"] = pkg()
+	manifest.Packages["github.com/fx/f"] = pkg("b", "c")
+
+	tests := []struct {
+		name  string
+		depth int
+		roots []string
+		want  string
+	}{
+		{
+			name:  "cycles are not reused",
+			roots: []string{"a", "f"},
+			want: "github.com/fx/a [github.com/fx/b [github.com/fx/a (cycle) github.com/fx/c [github.com/fx/d [github.com/fx/e]]]]\n" +
+				"github.com/fx/f [github.com/fx/b [github.com/fx/a [github.com/fx/b (cycle)] github.com/fx/c [github.com/fx/d [github.com/fx/e]]] github.com/fx/c [github.com/fx/d [github.com/fx/e]]]\n",
+		},
+		{
+			name:  "tree cut by depth is rebuilt at a lower level",
+			depth: 3,
+			roots: []string{"f", "c"},
+			want: "github.com/fx/f [github.com/fx/b [github.com/fx/a github.com/fx/c] github.com/fx/c [github.com/fx/d]]\n" +
+				"github.com/fx/c [github.com/fx/d [github.com/fx/e]]\n",
+		},
+		{
+			name:  "complete tree is cut at a higher level",
+			depth: 3,
+			roots: []string{"c", "f"},
+			want: "github.com/fx/c [github.com/fx/d [github.com/fx/e]]\n" +
+				"github.com/fx/f [github.com/fx/b [github.com/fx/a github.com/fx/c] github.com/fx/c [github.com/fx/d]]\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := build(tt.depth, tt.roots...); got != tt.want {
+				t.Errorf("buildTreeNode() = \n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+
+	// every pkg of a ladder depends on the next two, so unshared subtrees grow exponentially.
+	manifest = initManifest()
+	const n = 60
+	for i := 0; i < n; i++ {
+		var deps []string
+		for j := i + 1; j < n && j <= i+2; j++ {
+			deps = append(deps, fmt.Sprintf("p%d", j))
+		}
+		manifest.Packages[fmt.Sprintf("github.com/fx/p%d", i)] = pkg(deps...)
+	}
+	node, complete := buildTreeNode("github.com/fx/p0", make(map[string]struct{}), make(map[string]builtTreeNode), 1, 0)
+	if !complete {
+		t.Fatal("buildTreeNode() of a ladder is not complete")
+	}
+	if node.Deps[0].Deps[0] != node.Deps[1] {
+		t.Error("buildTreeNode() did not reuse tree of github.com/fx/p2")
+	}
+}
@@ -6,7 +6,7 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
-	"strings"
+	"runtime"
 	"syscall"
 
 	"github.com/spf13/cobra"
@@ -29,6 +29,12 @@ func main() {
 		update, updateDeps         bool
 		verbose                    bool
 		constraint                 bool
+		dryRun                     bool
+		jsonOut                    bool
+		depth                      int
+		jobs                       int
+		sum, force                 bool
+		tool                       string
 	)
 
 	var cmdGet = &cobra.Command{
@@ -83,13 +89,14 @@ func main() {
 	cmdGet.Flags().BoolVarP(&constraint, "constraint", "c", false, "add package with version to constraint")
 	cmdGet.Flags().BoolVarP(&update, "update", "u", false, "update package if exists")
 	cmdGet.Flags().BoolVarP(&update, "update-deps", "", false, "update package dependencies")
+	cmdGet.Flags().BoolVarP(&offline, "offline", "", offline, "use cached repositories only, can be set with VEN_OFFLINE env")
 
 	var cmdInstall = &cobra.Command{
 		Use:   "install",
 		Short: "Install installs vendor dependencies from manifest.",
 		Args:  cobra.ExactArgs(0),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			err := Install(ctx, verbose)
+			err := Install(ctx, jobs, verbose)
 			if !ctxCancelled(ctx) {
 				return err
 			}
@@ -99,6 +106,8 @@ func main() {
 		},
 	}
 	cmdInstall.Flags().BoolVarP(&verbose, "verbose", "v", false, "")
+	cmdInstall.Flags().IntVarP(&jobs, "jobs", "j", runtime.NumCPU(), "number of packages to download in parallel")
+	cmdInstall.Flags().BoolVarP(&offline, "offline", "", offline, "use cached repositories only, can be set with VEN_OFFLINE env")
 
 	var cmdInit = &cobra.Command{
 		Use:   "init",
@@ -121,15 +130,15 @@ func main() {
 		Short: "Fetch fetches dependencies for current project.",
 		Args:  cobra.ExactArgs(0),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			dir, err := os.Getwd()
+			pkg, err := currentPkg()
 			if err != nil {
-				return fmt.Errorf("cannot get current directory: %v", err)
+				return err
 			}
 			if vendorExists() {
 				return errors.New("vendor directory already exists")
 			}
 
-			err = Fetch(ctx, strings.TrimPrefix(dir, os.Getenv("GOPATH")+"/src/"), update, verbose)
+			err = Fetch(ctx, pkg, update, jobs, verbose)
 			if err != nil || ctxCancelled(ctx) {
 				os.RemoveAll(manifest.VendorPath)
 			}
@@ -138,11 +147,179 @@ func main() {
 		},
 	}
 	cmdFetch.Flags().BoolVarP(&verbose, "verbose", "v", false, "")
+	cmdFetch.Flags().IntVarP(&jobs, "jobs", "j", runtime.NumCPU(), "number of packages to download in parallel")
+	cmdFetch.Flags().BoolVarP(&offline, "offline", "", offline, "use cached repositories only, can be set with VEN_OFFLINE env")
+
+	var cmdMigrate = &cobra.Command{
+		Use:   "migrate",
+		Short: "Migrate converts glide, dep, godep or govendor project to ven.",
+		Long:  `migrate turns tool config into manifest settings and fetches exactly the revisions pinned by the tool lock file`,
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			pkg, err := currentPkg()
+			if err != nil {
+				return err
+			}
+
+			return Migrate(ctx, pkg, tool, excludeBuilds, jobs, verbose)
+		},
+	}
+	cmdMigrate.Flags().BoolVarP(&verbose, "verbose", "v", false, "")
+	cmdMigrate.Flags().StringVarP(&tool, "tool", "", "", "tool to migrate from: glide, dep, godep or govendor, detected by lock file if not set")
+	cmdMigrate.Flags().StringSliceVarP(&excludeBuilds, "exclude-builds", "", []string{"appenginevm", "appengine", "android", "integration", "ignore"}, "builds to exclude from import")
+	cmdMigrate.Flags().IntVarP(&jobs, "jobs", "j", runtime.NumCPU(), "number of packages to download in parallel")
+	cmdMigrate.Flags().BoolVarP(&offline, "offline", "", offline, "use cached repositories only, can be set with VEN_OFFLINE env")
+
+	var cmdRemove = &cobra.Command{
+		Use:   "remove [packages to remove]",
+		Short: "Remove removes packages with their orphaned dependencies.",
+		Long:  `remove refuses to remove packages still imported by the project`,
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			pkg, err := currentPkg()
+			if err != nil {
+				return err
+			}
+
+			return Remove(ctx, pkg, args, verbose)
+		},
+	}
+	cmdRemove.Flags().BoolVarP(&verbose, "verbose", "v", false, "")
+
+	var cmdPrune = &cobra.Command{
+		Use:   "prune",
+		Short: "Prune removes packages and subpackages not used by the project.",
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			pkg, err := currentPkg()
+			if err != nil {
+				return err
+			}
+
+			return Prune(ctx, pkg, dryRun, verbose)
+		},
+	}
+	cmdPrune.Flags().BoolVarP(&verbose, "verbose", "v", false, "")
+	cmdPrune.Flags().BoolVarP(&dryRun, "dry-run", "", false, "only list packages to remove")
+
+	var cmdList = &cobra.Command{
+		Use:   "list",
+		Short: "List lists packages from manifest.",
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return List(ctx, jsonOut)
+		},
+	}
+	cmdList.Flags().BoolVarP(&jsonOut, "json", "", false, "print in json format")
+
+	var cmdTree = &cobra.Command{
+		Use:   "tree",
+		Short: "Tree prints dependency tree of current project.",
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			pkg, err := currentPkg()
+			if err != nil {
+				return err
+			}
+
+			return Tree(ctx, pkg, depth, jsonOut, verbose)
+		},
+	}
+	cmdTree.Flags().BoolVarP(&verbose, "verbose", "v", false, "")
+	cmdTree.Flags().BoolVarP(&jsonOut, "json", "", false, "print in json format")
+	cmdTree.Flags().IntVarP(&depth, "depth", "d", 0, "max depth of a tree, 0 means unlimited")
+
+	var cmdWhy = &cobra.Command{
+		Use:   "why [package]",
+		Short: "Why explains which import chains require a package.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			pkg, err := currentPkg()
+			if err != nil {
+				return err
+			}
+
+			return Why(ctx, pkg, args[0], verbose)
+		},
+	}
+	cmdWhy.Flags().BoolVarP(&verbose, "verbose", "v", false, "")
+
+	var cmdOutdated = &cobra.Command{
+		Use:   "outdated",
+		Short: "Outdated compares pinned packages with the latest available tags.",
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return Outdated(ctx, verbose)
+		},
+	}
+	cmdOutdated.Flags().BoolVarP(&verbose, "verbose", "v", false, "")
+
+	var cmdVerify = &cobra.Command{
+		Use:   "verify",
+		Short: "Verify checks whether vendor directory matches manifest.",
+		Args:  cobra.ExactArgs(0),
+		// differences are already printed, usage would only hide them.
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return Verify(ctx, verbose)
+		},
+	}
+	cmdVerify.Flags().BoolVarP(&verbose, "verbose", "v", false, "")
+
+	var cmdCache = &cobra.Command{
+		Use:   "cache",
+		Short: "Cache manages shared cache of repositories.",
+	}
+	var cmdCacheList = &cobra.Command{
+		Use:   "list",
+		Short: "List lists cached repositories.",
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return CacheList(ctx)
+		},
+	}
+	var cmdCacheClean = &cobra.Command{
+		Use:   "clean [packages to remove]",
+		Short: "Clean removes specified repositories from cache, or the whole cache.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return CacheClean(ctx, args, verbose)
+		},
+	}
+	cmdCacheClean.Flags().BoolVarP(&verbose, "verbose", "v", false, "")
+	var cmdCacheVerify = &cobra.Command{
+		Use:   "verify",
+		Short: "Verify checks integrity of cached repositories.",
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return CacheVerify(ctx, verbose)
+		},
+	}
+	cmdCacheVerify.Flags().BoolVarP(&verbose, "verbose", "v", false, "")
+	cmdCache.AddCommand(cmdCacheList, cmdCacheClean, cmdCacheVerify)
+
+	var cmdExport = &cobra.Command{
+		Use:   "export",
+		Short: "Export converts manifest into other formats.",
+	}
+	var cmdExportGoMod = &cobra.Command{
+		Use:   "gomod",
+		Short: "Gomod generates go.mod, and optionally go.sum, from manifest.",
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return ExportGoMod(ctx, sum, force, verbose)
+		},
+	}
+	cmdExportGoMod.Flags().BoolVar(&sum, "sum", false, "generate go.sum from package commits")
+	cmdExportGoMod.Flags().BoolVar(&force, "force", false, "overwrite existing go.mod and go.sum")
+	cmdExportGoMod.Flags().BoolVarP(&verbose, "verbose", "v", false, "")
+	cmdExport.AddCommand(cmdExportGoMod)
 
 	var rootCmd = &cobra.Command{Use: "ven"}
-	rootCmd.AddCommand(cmdInit, cmdFetch, cmdGet, cmdInstall)
+	rootCmd.AddCommand(cmdInit, cmdFetch, cmdMigrate, cmdGet, cmdInstall, cmdRemove, cmdPrune, cmdList, cmdTree, cmdWhy, cmdOutdated, cmdVerify, cmdCache, cmdExport)
 
-	rootCmd.Execute()
+	if err := rootCmd.Execute(); err != nil {
+		os.Exit(1)
+	}
 }
 
 func ctxCancelled(сtx context.Context) bool {
@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	"path"
 	"sort"
 	"strings"
 
@@ -18,6 +19,18 @@ type Manifest struct {
 	ExcludeBuild    map[string]struct{}
 	ExcludePackages map[string]struct{}
 	LocalPackages   map[string]struct{}
+	// ConflictPolicy decides which version to import if dependencies require different ones.
+	ConflictPolicy string
+	// Targets are platforms project is built for, files not built for any of them are not scanned.
+	Targets []buildTarget
+	// BuildTags are custom tags set for every target.
+	BuildTags []string
+	// IncludeTests tells whose test imports are vendored: none, project or all.
+	IncludeTests string
+	// KeepFiles are globs of non-go files kept in vendored packages.
+	KeepFiles []string
+	// PkgKeepFiles override KeepFiles for specific packages.
+	PkgKeepFiles map[string][]string
 
 	Constraints map[string]string
 	Packages    map[string]Package
@@ -30,9 +43,15 @@ type ManifestYaml struct {
 	ExcludeBuild    []string `yaml:"exclude_build"`
 	ExcludePackages []string `yaml:"exclude_packages"`
 	LocalPackages   []string `yaml:"local_packages"`
-
-	Constraints map[string]string
-	Packages    map[string]PackageYaml
+	ConflictPolicy  string   `yaml:"conflict_policy,omitempty"` // highest, manifest or fail, defaults to highest
+	Targets         []string `yaml:"targets,omitempty"`         // GOOS/GOARCH pairs
+	BuildTags       []string `yaml:"build_tags,omitempty"`
+	IncludeTests    string   `yaml:"include_tests,omitempty"` // none, project or all, defaults to none
+	KeepFiles       []string `yaml:"keep_files,omitempty"`
+
+	PkgKeepFiles map[string][]string `yaml:"package_keep_files,omitempty"`
+	Constraints  map[string]string
+	Packages     map[string]PackageYaml
 }
 
 // Package describes manifest package.
@@ -40,16 +59,30 @@ type Package struct {
 	Name        string
 	Version     string
 	CommitHash  string
+	Hash        string
+	VCS         string
+	Source      string
+	Test        bool
 	Subpackages map[string]struct{}
 	Deps        map[string]struct{}
+	// TestDeps are imports of pkg test files, kept if manifest includes tests of all packages.
+	TestDeps map[string]struct{}
+	// Pruned are subpackages removed by prune, their files are dropped whenever pkg is vendored and are not hashed.
+	Pruned map[string]struct{}
 }
 
 // PackageYaml describes manifest package in a yaml file.
 type PackageYaml struct {
 	Version     string
 	CommitHash  string
+	Hash        string
+	VCS         string `yaml:"vcs,omitempty"`
+	Source      string `yaml:"source,omitempty"`
+	Test        bool   `yaml:"test,omitempty"`
 	Subpackages []string
 	Deps        []string
+	TestDeps    []string `yaml:"test_deps,omitempty"`
+	Pruned      []string `yaml:"pruned,omitempty"`
 }
 
 var manifest *Manifest
@@ -197,6 +230,39 @@ func parseManifest() (*Manifest, error) {
 	for _, pkg := range cfg.ExcludePackages {
 		m.ExcludePackages[pkg] = struct{}{}
 	}
+	switch cfg.ConflictPolicy {
+	case "", conflictHighest, conflictManifest, conflictFail:
+		m.ConflictPolicy = cfg.ConflictPolicy
+	default:
+		return nil, fmt.Errorf("unknown conflict_policy (%s), should be one of: %s, %s, %s", cfg.ConflictPolicy, conflictHighest, conflictManifest, conflictFail)
+	}
+	for _, target := range cfg.Targets {
+		t, err := parseBuildTarget(target)
+		if err != nil {
+			return nil, err
+		}
+		m.Targets = append(m.Targets, t)
+	}
+	m.BuildTags = cfg.BuildTags
+	switch cfg.IncludeTests {
+	case "", testsNone, testsProject, testsAll:
+		m.IncludeTests = cfg.IncludeTests
+	default:
+		return nil, fmt.Errorf("unknown include_tests (%s), should be one of: %s, %s, %s", cfg.IncludeTests, testsNone, testsProject, testsAll)
+	}
+	for _, glob := range cfg.KeepFiles {
+		if _, err := path.Match(glob, ""); err != nil {
+			return nil, fmt.Errorf("bad keep_files pattern (%s): %v", glob, err)
+		}
+	}
+	for pkg, globs := range cfg.PkgKeepFiles {
+		for _, glob := range globs {
+			if _, err := path.Match(glob, ""); err != nil {
+				return nil, fmt.Errorf("pkg (%s): bad package_keep_files pattern (%s): %v", pkg, glob, err)
+			}
+		}
+	}
+	m.KeepFiles, m.PkgKeepFiles = cfg.KeepFiles, cfg.PkgKeepFiles
 	for name, pkgYaml := range cfg.Packages {
 		depsMap := make(map[string]struct{})
 		for _, dep := range pkgYaml.Deps {
@@ -206,13 +272,30 @@ func parseManifest() (*Manifest, error) {
 		for _, subpkg := range pkgYaml.Subpackages {
 			subpkgsMap[subpkg] = struct{}{}
 		}
+		testDepsMap := make(map[string]struct{})
+		for _, dep := range pkgYaml.TestDeps {
+			testDepsMap[dep] = struct{}{}
+		}
+		var prunedMap map[string]struct{}
+		for _, subpkg := range pkgYaml.Pruned {
+			if prunedMap == nil {
+				prunedMap = make(map[string]struct{})
+			}
+			prunedMap[subpkg] = struct{}{}
+		}
 
 		m.Packages[name] = Package{
 			Name:        name,
 			CommitHash:  pkgYaml.CommitHash,
 			Version:     pkgYaml.Version,
+			Hash:        pkgYaml.Hash,
+			VCS:         pkgYaml.VCS,
+			Source:      pkgYaml.Source,
+			Test:        pkgYaml.Test,
 			Subpackages: subpkgsMap,
 			Deps:        depsMap,
+			TestDeps:    testDepsMap,
+			Pruned:      prunedMap,
 		}
 	}
 	m.Constraints = cfg.Constraints
@@ -227,9 +310,17 @@ func saveManifest() error {
 		ExcludeDir:      make([]string, 0, 4),
 		LocalPackages:   make([]string, 0, 4),
 		ExcludePackages: make([]string, 0, 4),
+		ConflictPolicy:  manifest.ConflictPolicy,
+		BuildTags:       manifest.BuildTags,
+		IncludeTests:    manifest.IncludeTests,
+		KeepFiles:       manifest.KeepFiles,
+		PkgKeepFiles:    manifest.PkgKeepFiles,
 		Constraints:     manifest.Constraints,
 		Packages:        make(map[string]PackageYaml),
 	}
+	for _, target := range manifest.Targets {
+		cfg.Targets = append(cfg.Targets, target.String())
+	}
 	for build := range manifest.ExcludeBuild {
 		cfg.ExcludeBuild = append(cfg.ExcludeBuild, build)
 	}
@@ -253,12 +344,28 @@ func saveManifest() error {
 			subpkgs = append(subpkgs, subpkg)
 		}
 		sort.Strings(subpkgs)
+		var testDeps []string
+		for dep := range pkg.TestDeps {
+			testDeps = append(testDeps, dep)
+		}
+		sort.Strings(testDeps)
+		var pruned []string
+		for subpkg := range pkg.Pruned {
+			pruned = append(pruned, subpkg)
+		}
+		sort.Strings(pruned)
 
 		cfg.Packages[name] = PackageYaml{
 			CommitHash:  pkg.CommitHash,
 			Version:     pkg.Version,
+			Hash:        pkg.Hash,
+			VCS:         pkg.VCS,
+			Source:      pkg.Source,
+			Test:        pkg.Test,
 			Subpackages: subpkgs,
 			Deps:        deps,
+			TestDeps:    testDeps,
+			Pruned:      pruned,
 		}
 	}
 
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cliqueinc/ven/parse"
+)
+
+// Migrate converts project managed by another tool to ven: tool config becomes manifest exclusions and constraints,
+// revisions from the tool lock file are pinned exactly, so vendor is fetched the same as the tool made it.
+// Existing vendor is moved aside during migration and restored if it fails.
+func Migrate(ctx context.Context, pkg, tool string, excludeBuilds []string, jobs int, verbose bool) error {
+	if _, err := os.Stat("Manifest.yml"); err == nil || !os.IsNotExist(err) {
+		return errors.New("manifest already exists")
+	}
+
+	tool, err := migrateTool(tool)
+	if err != nil {
+		return err
+	}
+	cfg, err := parse.Parsers[tool].(parse.ConfigParser).ParseConfig("./")
+	if err != nil {
+		return fmt.Errorf("failed parse (%s) config: %v", tool, err)
+	}
+	if verbose {
+		fmt.Printf("migrating from %s\n", tool)
+	}
+
+	for _, build := range excludeBuilds {
+		manifest.ExcludeBuild[build] = struct{}{}
+	}
+	for _, build := range cfg.ExcludeBuild {
+		manifest.ExcludeBuild[build] = struct{}{}
+	}
+	for _, dir := range cfg.ExcludeDirs {
+		manifest.ExcludeDir[dir] = struct{}{}
+	}
+	for _, ignored := range cfg.Ignore {
+		manifest.ExcludePackages[ignored] = struct{}{}
+	}
+	for name, version := range cfg.Constraints {
+		manifest.Constraints[name] = version
+	}
+
+	// govendor keeps lock file in vendor, so it's parsed before vendor is moved.
+	if _, err := cacheLockFile(pkg, "./", tool, true, verbose); err != nil {
+		return err
+	}
+
+	// vendor is stashed to a hidden dir, so old vendored code isn't scanned as project code.
+	stash, err := stashDirs([]string{manifest.VendorPath})
+	if err != nil {
+		return fmt.Errorf("cannot backup vendor: %v", err)
+	}
+	if err := fetchProject(ctx, pkg, false, jobs, verbose); err != nil {
+		if err := os.RemoveAll(manifest.VendorPath); err != nil {
+			fmt.Printf("cannot delete vendor: %v\n", err)
+		}
+		if err := stash.Restore(); err != nil {
+			fmt.Printf("cannot restore vendor: %v\n", err)
+		}
+		return err
+	}
+
+	return stash.Drop()
+}
+
+// migrateTool returns tool to migrate from, detected by its files if not set.
+func migrateTool(tool string) (string, error) {
+	if tool != "" {
+		p, ok := parse.Parsers[tool]
+		if !ok {
+			return "", fmt.Errorf("unknown tool (%s)", tool)
+		}
+		if _, ok := p.(parse.ConfigParser); !ok {
+			return "", fmt.Errorf("migration from %s is not supported", tool)
+		}
+		if !p.Check("./") {
+			return "", fmt.Errorf("%s lock file not found", tool)
+		}
+		return tool, nil
+	}
+
+	var supported []string
+	for _, name := range parse.Names() {
+		p := parse.Parsers[name]
+		if _, ok := p.(parse.ConfigParser); !ok {
+			continue
+		}
+		if p.Check("./") {
+			return name, nil
+		}
+		supported = append(supported, name)
+	}
+
+	return "", fmt.Errorf("no lock file of supported tools found (%s)", strings.Join(supported, ", "))
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrate(t *testing.T) {
+	dir := setupProject(t)
+	addTestRepo(t, dir, "github.com/fx/a", []testCommit{
+		{Files: map[string]string{
+			"a.go":       "package a\n\nimport _ \"github.com/fx/c\"\n",
+			"glide.lock": "imports:\n- name: github.com/fx/c\n  version: v1.1.0\n",
+		}},
+	})
+	hashes := addTestRepo(t, dir, "github.com/fx/c", []testCommit{
+		{Files: map[string]string{"c.go": "package c\n"}, Tag: "v1.0.0"},
+		{Files: map[string]string{"c.go": "package c\n\nconst V = 1\n"}, Tag: "v1.1.0"},
+	})
+	addTestRepo(t, dir, "github.com/fx/stale", []testCommit{
+		{Files: map[string]string{"stale.go": "package stale\n"}},
+	})
+
+	writeFiles(t, ".", map[string]string{
+		"main.go":           "package main\n\nimport (\n\t_ \"github.com/fx/a\"\n\t_ \"github.com/fx/c\"\n\t_ \"github.com/fx/ignored\"\n)\n\nfunc main() {}\n",
+		"tools/tools.go":    "package tools\n\nimport _ \"github.com/fx/tool\"\n",
+		"glide.yaml":        "package: example.com/proj\nexcludeDirs:\n- tools\nignore:\n- github.com/fx/ignored\nimport:\n- package: github.com/fx/c\n  version: ^1.0.0\n",
+		"glide.lock":        "imports:\n- name: github.com/fx/a\n  version: master\n- name: github.com/fx/c\n  version: " + hashes[0] + "\n",
+		"vendor/old/old.go": "package old\n\nimport _ \"github.com/fx/stale\"\n",
+	})
+
+	if err := Migrate(context.Background(), "example.com/proj", "", nil, 2, false); err != nil {
+		t.Fatalf("Migrate() error: %v", err)
+	}
+
+	if c := manifest.Packages["github.com/fx/c"]; c.CommitHash != hashes[0] || c.Version != "v1.0.0" {
+		t.Errorf("github.com/fx/c = %s %s, want %s v1.0.0 pinned by project lock file", c.Version, c.CommitHash, hashes[0])
+	}
+	if got := manifest.Constraints["github.com/fx/c"]; got != "^1.0.0" {
+		t.Errorf("github.com/fx/c constraint = %q, want ^1.0.0", got)
+	}
+	if _, ok := manifest.ExcludePackages["github.com/fx/ignored"]; !ok {
+		t.Error("github.com/fx/ignored is not excluded")
+	}
+	if _, ok := manifest.ExcludeDir["tools"]; !ok {
+		t.Error("tools dir is not excluded")
+	}
+	if _, err := os.Stat("vendor/old"); !os.IsNotExist(err) {
+		t.Errorf("old vendor is kept: %v", err)
+	}
+	if _, ok := manifest.Packages["github.com/fx/stale"]; ok {
+		t.Error("github.com/fx/stale imported by old vendor is fetched")
+	}
+	if backups, _ := filepath.Glob(".ven-*"); len(backups) != 0 {
+		t.Errorf("vendor backup is kept: %v", backups)
+	}
+	if _, err := os.Stat("Manifest.yml"); err != nil {
+		t.Errorf("Manifest.yml is not saved: %v", err)
+	}
+}
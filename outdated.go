@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// outdatedEntry describes how far pinned pkg is behind its repo.
+type outdatedEntry struct {
+	Name       string
+	Version    string
+	CommitHash string
+	Latest     string
+	Wanted     string
+	// Behind is a number of commits between pinned commit and the latest tag (or HEAD if repo has no tags), -1 if unknown.
+	Behind int
+	Err    error
+}
+
+// Outdated prints manifest packages with their latest available versions.
+func Outdated(ctx context.Context, verbose bool) error {
+	names := make([]string, 0, len(manifest.Packages))
+	for name := range manifest.Packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PACKAGE\tVERSION\tCOMMIT\tLATEST\tWANTED\tBEHIND")
+	for _, name := range names {
+		if ctxCancelled(ctx) {
+			return ctx.Err()
+		}
+
+		e := getOutdated(ctx, name, verbose)
+		if e.Err != nil {
+			fmt.Fprintf(w, "%s\t%s\t%s\terror: %v\t\t\n", e.Name, e.Version, shortHash(e.CommitHash), e.Err)
+			continue
+		}
+		behind := "?"
+		if e.Behind >= 0 {
+			behind = strconv.Itoa(e.Behind)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", e.Name, e.Version, shortHash(e.CommitHash), e.Latest, e.Wanted, behind)
+	}
+
+	return w.Flush()
+}
+
+// getOutdated compares pinned commit of pkg with tags of its repo mirror in cache, the mirror is updated first.
+func getOutdated(ctx context.Context, pkg string, verbose bool) outdatedEntry {
+	info := manifest.Packages[pkg]
+	e := outdatedEntry{
+		Name:       pkg,
+		Version:    info.Version,
+		CommitHash: info.CommitHash,
+		Behind:     -1,
+	}
+
+	dir, err := getRepoDir(ctx, pkg, verbose)
+	if err != nil {
+		e.Err = err
+		return e
+	}
+	out, err := runGit(ctx, dir, "tag", "--list")
+	if err != nil {
+		e.Err = fmt.Errorf("cannot list tags: %v", err)
+		return e
+	}
+	tags := strings.Fields(out)
+
+	e.Latest = latestTag(tags, nil)
+	if _, constraint, exists := manifest.GetPkgConstraint(pkg); exists && constraint != "" {
+		e.Wanted = latestTag(tags, func(tag string) bool {
+			return constraintAllows(constraint, tag)
+		})
+	}
+
+	target := "HEAD"
+	if e.Latest != "" {
+		target = "refs/tags/" + e.Latest
+	}
+	out, err = runGit(ctx, dir, "rev-list", "--count", info.CommitHash+".."+target)
+	if err == nil {
+		if n, err := strconv.Atoi(strings.TrimSpace(out)); err == nil {
+			e.Behind = n
+		}
+	}
+
+	return e
+}
+
+// getRepoDir returns a local repository of a manifest pkg: pkg dir for local pkgs, or an updated cache mirror.
+func getRepoDir(ctx context.Context, pkg string, verbose bool) (string, error) {
+	if _, local := manifest.IsLocalPkg(pkg); local {
+		dir, ok := gopathPkgDir(pkg)
+		if !ok {
+			return "", fmt.Errorf("pkg (%s): local pkg is not found in GOPATH", pkg)
+		}
+		return dir, nil
+	}
+
+	info := manifest.Packages[pkg]
+	src, err := resolveRepo(pkg, info.Source, info.VCS, false)
+	if err != nil {
+		return "", err
+	}
+	if src.VCS != "git" {
+		return "", fmt.Errorf("pkg (%s): outdated supports only git repos, pkg uses %s", pkg, src.VCS)
+	}
+	if verbose {
+		fmt.Printf("pkg (%s): checking %s\n", pkg, src.Repo)
+	}
+
+	return syncCache(ctx, src.CacheRoot(), src.Repo)
+}
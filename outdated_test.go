@@ -0,0 +1,26 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func Test_getOutdated(t *testing.T) {
+	dir := setupProject(t)
+	hashes := addTestRepo(t, dir, "github.com/pkg/lib", []testCommit{
+		{Files: map[string]string{"lib.go": "package lib\n"}, Tag: "v1.0.0"},
+		{Files: map[string]string{"lib.go": "package lib\n\nconst A = 1\n"}, Tag: "v1.1.0"},
+		{Files: map[string]string{"lib.go": "package lib\n\nconst A = 2\n"}, Tag: "v2.0.0"},
+	})
+
+	manifest.Packages["github.com/pkg/lib"] = Package{Version: "v1.0.0", CommitHash: hashes[0]}
+	manifest.Constraints["github.com/pkg/lib"] = "v1.1.0"
+
+	got := getOutdated(context.Background(), "github.com/pkg/lib", false)
+	if got.Err != nil {
+		t.Fatalf("getOutdated() error: %v", got.Err)
+	}
+	if got.Latest != "v2.0.0" || got.Wanted != "v1.1.0" || got.Behind != 2 {
+		t.Errorf("getOutdated() = latest %s, wanted %s, behind %d; want v2.0.0, v1.1.0, 2", got.Latest, got.Wanted, got.Behind)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strings"
 
 	"github.com/pelletier/go-toml"
 )
@@ -14,8 +15,11 @@ type DepParser struct{}
 // depFile represents dep file.
 type depFile struct {
 	Projects []struct {
-		Name     string `toml:"name"`
-		Revision string `toml:"revision"`
+		Name     string   `toml:"name"`
+		Source   string   `toml:"source"`
+		Revision string   `toml:"revision"`
+		Version  string   `toml:"version"`
+		Packages []string `toml:"packages"`
 	} `toml:"projects"`
 }
 
@@ -43,14 +47,108 @@ func (p DepParser) Parse(repoPath string) ([]Package, error) {
 	packages := make([]Package, 0, len(cfgFile.Projects))
 	for _, info := range cfgFile.Projects {
 		packages = append(packages, Package{
-			Name:       info.Name,
-			CommitHash: info.Revision,
+			Name:        info.Name,
+			CommitHash:  info.Revision,
+			Version:     info.Version,
+			Source:      info.Source,
+			Subpackages: subpackagePaths(info.Name, info.Packages),
 		})
 	}
 
 	return packages, nil
 }
 
+// depConfigFile represents dep config file.
+type depConfigFile struct {
+	Constraints []depConstraint `toml:"constraint"`
+	Overrides   []depConstraint `toml:"override"`
+	Ignored     []string        `toml:"ignored"`
+}
+
+type depConstraint struct {
+	Name     string `toml:"name"`
+	Version  string `toml:"version"`
+	Branch   string `toml:"branch"`
+	Revision string `toml:"revision"`
+}
+
+// ParseConfig parses Gopkg.toml for constraints, overrides and ignored packages.
+// Dep treats a bare version like 1.2.0 as ^1.2.0.
+func (p DepParser) ParseConfig(repoPath string) (*Config, error) {
+	cfgFile := depConfigFile{}
+	data, err := ioutil.ReadFile(repoPath + "/Gopkg.toml")
+	if os.IsNotExist(err) {
+		return &Config{Constraints: make(map[string]string)}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("fail read Gopkg.toml file: %v", err)
+	}
+
+	if err := toml.Unmarshal(data, &cfgFile); err != nil {
+		return nil, fmt.Errorf("fail unmarshal Gopkg.toml file: %v", err)
+	}
+
+	cfg := &Config{
+		Constraints: make(map[string]string),
+		Ignore:      cfgFile.Ignored,
+	}
+	// overrides go last, so they replace constraints.
+	for _, c := range append(cfgFile.Constraints, cfgFile.Overrides...) {
+		switch {
+		case c.Version != "":
+			version, err := depVersionConstraint(c.Version)
+			if err != nil {
+				return nil, fmt.Errorf("pkg (%s): %v", c.Name, err)
+			}
+			if version == "" {
+				// any version is allowed.
+				delete(cfg.Constraints, c.Name)
+				continue
+			}
+			cfg.Constraints[c.Name] = version
+		case c.Branch != "":
+			cfg.Constraints[c.Name] = c.Branch
+		case c.Revision != "":
+			cfg.Constraints[c.Name] = c.Revision
+		}
+	}
+
+	return cfg, nil
+}
+
+// depVersionConstraint converts dep version constraint to ven one. Dep separates range terms with commas,
+// ven with spaces, * terms allow any version and are dropped, so empty result means no constraint.
+// Exclusions (!=) and alternatives (||) can't be expressed by ven ranges.
+func depVersionConstraint(version string) (string, error) {
+	if strings.Contains(version, "||") {
+		return "", fmt.Errorf("version (%s): alternative ranges are not supported", version)
+	}
+
+	var terms []string
+	for _, term := range strings.Fields(strings.Replace(version, ",", " ", -1)) {
+		switch {
+		case term == "*":
+			continue
+		case strings.HasPrefix(term, "!="):
+			return "", fmt.Errorf("version (%s): exclusions are not supported", version)
+		}
+		terms = append(terms, term)
+	}
+
+	switch {
+	case len(terms) == 0:
+		return "", nil
+	case len(terms) > 1:
+		return strings.Join(terms, " "), nil
+	case strings.HasPrefix(terms[0], "="):
+		return strings.TrimPrefix(terms[0], "="), nil
+	case !strings.ContainsAny(terms[0][:1], "^~<>"):
+		return "^" + terms[0], nil
+	}
+
+	return terms[0], nil
+}
+
 func init() {
 	Parsers["dep"] = DepParser{}
 }
@@ -0,0 +1,27 @@
+package parse
+
+import "os"
+
+// GbParser parses manifest file of gb vendor plugin, gb projects keep their code in src dir.
+type GbParser struct{}
+
+// Check checks whether repo is a gb project.
+func (p GbParser) Check(repoPath string) bool {
+	if _, err := os.Stat(repoPath + "/vendor/manifest"); err != nil {
+		return false
+	}
+	if f, err := os.Stat(repoPath + "/src"); err != nil || !f.IsDir() {
+		return false
+	}
+
+	return true
+}
+
+// Parse parses gb manifest file for imports.
+func (p GbParser) Parse(repoPath string) ([]Package, error) {
+	return parseVendorManifest(repoPath)
+}
+
+func init() {
+	Parsers["gb"] = GbParser{}
+}
@@ -14,8 +14,11 @@ type GlideParser struct{}
 // glideLockFile represents glide lock file.
 type glideLockFile struct {
 	Imports []struct {
-		Name    string
-		Version string
+		Name        string
+		Version     string
+		Repo        string
+		VCS         string
+		Subpackages []string
 	}
 }
 
@@ -43,14 +46,56 @@ func (p GlideParser) Parse(repoPath string) ([]Package, error) {
 	packages := make([]Package, 0, len(lockFile.Imports))
 	for _, info := range lockFile.Imports {
 		packages = append(packages, Package{
-			Name:       info.Name,
-			CommitHash: info.Version,
+			Name:        info.Name,
+			CommitHash:  info.Version,
+			Source:      info.Repo,
+			VCS:         info.VCS,
+			Subpackages: subpackagePaths(info.Name, info.Subpackages),
 		})
 	}
 
 	return packages, nil
 }
 
+// glideConfigFile represents glide config file.
+type glideConfigFile struct {
+	Import []struct {
+		Package string
+		Version string
+	}
+	Ignore      []string
+	ExcludeDirs []string `yaml:"excludeDirs"`
+}
+
+// ParseConfig parses glide.yaml for versions of imports, ignored packages and excluded dirs.
+func (p GlideParser) ParseConfig(repoPath string) (*Config, error) {
+	cfgFile := glideConfigFile{}
+	data, err := ioutil.ReadFile(repoPath + "/glide.yaml")
+	if os.IsNotExist(err) {
+		return &Config{Constraints: make(map[string]string)}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("fail read glide.yaml: %v", err)
+	}
+
+	if err := yaml.Unmarshal(data, &cfgFile); err != nil {
+		return nil, fmt.Errorf("fail unmarshal glide.yaml file: %v", err)
+	}
+
+	cfg := &Config{
+		Constraints: make(map[string]string),
+		Ignore:      cfgFile.Ignore,
+		ExcludeDirs: cfgFile.ExcludeDirs,
+	}
+	for _, imp := range cfgFile.Import {
+		if imp.Version != "" {
+			cfg.Constraints[imp.Package] = imp.Version
+		}
+	}
+
+	return cfg, nil
+}
+
 func init() {
 	Parsers["glide"] = GlideParser{}
 }
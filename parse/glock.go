@@ -0,0 +1,20 @@
+package parse
+
+// GlockParser parses glock GLOCKFILE.
+type GlockParser struct{}
+
+// Check checks whether repo uses glock.
+func (p GlockParser) Check(repoPath string) bool {
+	return isFile(repoPath + "/GLOCKFILE")
+}
+
+// Parse parses GLOCKFILE lines like "import/path revision", "cmd import/path" lines are skipped.
+func (p GlockParser) Parse(repoPath string) ([]Package, error) {
+	return parseLinesFile(repoPath+"/GLOCKFILE", func(fields []string) bool {
+		return fields[0] == "cmd"
+	})
+}
+
+func init() {
+	Parsers["glock"] = GlockParser{}
+}
@@ -15,6 +15,7 @@ type GodepParser struct{}
 type godepLockFile struct {
 	Deps []struct {
 		ImportPath string
+		Comment    string
 		Rev        string
 	}
 }
@@ -45,12 +46,19 @@ func (p GodepParser) Parse(repoPath string) ([]Package, error) {
 		packages = append(packages, Package{
 			Name:       info.ImportPath,
 			CommitHash: info.Rev,
+			// godep comment is git describe output, like v1.4.0 or v1.1-7-g08b5f42.
+			Version: info.Comment,
 		})
 	}
 
 	return packages, nil
 }
 
+// ParseConfig returns empty config, godep has no settings besides pinned revisions.
+func (p GodepParser) ParseConfig(repoPath string) (*Config, error) {
+	return &Config{Constraints: make(map[string]string)}, nil
+}
+
 func init() {
 	Parsers["godep"] = GodepParser{}
 }
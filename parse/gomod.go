@@ -0,0 +1,210 @@
+package parse
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// GoModParser parses go modules file.
+type GoModParser struct{}
+
+// goModFile represents directives of go.mod file ven is interested in.
+type goModFile struct {
+	Require map[string]string
+	// Replace maps module path and replaced version, empty for all versions, to replacement path and version.
+	Replace map[string]map[string][2]string
+	// Exclude keeps excluded versions of module paths.
+	Exclude map[string]map[string]struct{}
+	// order keeps required modules in order of appearance.
+	order []string
+}
+
+// pseudoVersionRe matches pseudo-versions like v0.0.0-20170101000000-abcdef123456,
+// v1.2.4-0.20170101000000-abcdef123456 and v1.2.3-pre.0.20170101000000-abcdef123456.
+var pseudoVersionRe = regexp.MustCompile(`^v\d+\.\d+\.\d+-(?:.*\.)?(?:0\.)?\d{14}-([0-9a-f]{12})(?:\+incompatible)?$`)
+
+// majorSuffixRe matches major version suffix of module path, like /v2.
+var majorSuffixRe = regexp.MustCompile(`/v[2-9][0-9]*$`)
+
+// Check checks whether repo uses go modules.
+func (p GoModParser) Check(repoPath string) bool {
+	if _, err := os.Stat(repoPath + "/go.mod"); err == nil {
+		return true
+	}
+
+	return false
+}
+
+// Parse parses go.mod file for required modules.
+// Pseudo-versions are resolved to commit hashes, excluded versions are not pinned,
+// replacements by another module are used as pkg source, local replacements are skipped.
+// Replacement of a specific version applies to that version only.
+func (p GoModParser) Parse(repoPath string) ([]Package, error) {
+	data, err := ioutil.ReadFile(repoPath + "/go.mod")
+	if err != nil {
+		return nil, fmt.Errorf("fail read go.mod file: %v", err)
+	}
+
+	modFile, err := parseGoMod(data)
+	if err != nil {
+		return nil, fmt.Errorf("fail parse go.mod file: %v", err)
+	}
+
+	packages := make([]Package, 0, len(modFile.order))
+	for _, path := range modFile.order {
+		var (
+			version = modFile.Require[path]
+			source  string
+		)
+		if repl, ok := modFile.replacement(path, version); ok {
+			if repl[1] == "" {
+				// local dirs can't be fetched.
+				continue
+			}
+			version = repl[1]
+			if repl[0] != path {
+				source = majorSuffixRe.ReplaceAllString(repl[0], "")
+			}
+		} else if _, ok := modFile.Exclude[path][version]; ok {
+			version = ""
+		}
+
+		pkg := Package{
+			Name:       majorSuffixRe.ReplaceAllString(path, ""),
+			CommitHash: goModRevision(version),
+			Source:     source,
+		}
+		if !pseudoVersionRe.MatchString(version) {
+			pkg.Version = pkg.CommitHash
+		}
+		packages = append(packages, pkg)
+	}
+
+	return packages, nil
+}
+
+// goModRevision converts module version into a tag or commit hash.
+func goModRevision(version string) string {
+	if m := pseudoVersionRe.FindStringSubmatch(version); m != nil {
+		return m[1]
+	}
+
+	return strings.TrimSuffix(version, "+incompatible")
+}
+
+// parseGoMod parses require, replace and exclude directives, both single line and block forms.
+func parseGoMod(data []byte) (*goModFile, error) {
+	modFile := &goModFile{
+		Require: make(map[string]string),
+		Replace: make(map[string]map[string][2]string),
+		Exclude: make(map[string]map[string]struct{}),
+	}
+
+	var (
+		block  string
+		lineNo int
+	)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		if i := strings.Index(line, "//"); i != -1 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		for i, f := range fields {
+			fields[i] = strings.Trim(f, `"`)
+		}
+
+		verb := block
+		switch {
+		case block != "" && fields[0] == ")":
+			block = ""
+			continue
+		case block == "" && len(fields) == 2 && fields[1] == "(":
+			block = fields[0]
+			continue
+		case block == "":
+			verb, fields = fields[0], fields[1:]
+		}
+
+		if err := modFile.add(verb, fields); err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNo, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return modFile, nil
+}
+
+// add adds directive with its arguments, unknown directives are skipped.
+func (f *goModFile) add(verb string, args []string) error {
+	switch verb {
+	case "require":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: require module/path v1.2.3")
+		}
+		if _, ok := f.Require[args[0]]; !ok {
+			f.order = append(f.order, args[0])
+		}
+		f.Require[args[0]] = args[1]
+	case "exclude":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: exclude module/path v1.2.3")
+		}
+		if f.Exclude[args[0]] == nil {
+			f.Exclude[args[0]] = make(map[string]struct{})
+		}
+		f.Exclude[args[0]][args[1]] = struct{}{}
+	case "replace":
+		arrow := -1
+		for i, arg := range args {
+			if arg == "=>" {
+				arrow = i
+			}
+		}
+		if arrow < 1 || arrow > 2 || len(args)-arrow < 2 || len(args)-arrow > 3 {
+			return fmt.Errorf("usage: replace module/path [v1.2.3] => other/module v1.4.5 or local/dir")
+		}
+		var repl [2]string
+		repl[0] = args[arrow+1]
+		if len(args)-arrow == 3 {
+			repl[1] = args[arrow+2]
+		}
+		var version string
+		if arrow == 2 {
+			version = args[1]
+		}
+		if f.Replace[args[0]] == nil {
+			f.Replace[args[0]] = make(map[string][2]string)
+		}
+		f.Replace[args[0]][version] = repl
+	}
+
+	return nil
+}
+
+// replacement returns replacement of module version, replacement of the exact version takes precedence over
+// replacement of all versions, as in go toolchain.
+func (f *goModFile) replacement(path, version string) ([2]string, bool) {
+	if repl, ok := f.Replace[path][version]; ok && version != "" {
+		return repl, true
+	}
+	repl, ok := f.Replace[path][""]
+
+	return repl, ok
+}
+
+func init() {
+	Parsers["gomod"] = GoModParser{}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strings"
 )
 
 // GovendorParser parses govendor file.
@@ -12,9 +13,13 @@ type GovendorParser struct{}
 
 // govendorFile represents govendor file.
 type govendorFile struct {
+	// Ignore is space separated build tags and package prefixes.
+	Ignore  string `json:"ignore"`
 	Package []struct {
 		Path     string `json:"path"`
+		Origin   string `json:"origin"`
 		Revision string `json:"revision"`
+		Version  string `json:"version"`
 	} `json:"package"`
 }
 
@@ -41,15 +46,48 @@ func (p GovendorParser) Parse(repoPath string) ([]Package, error) {
 
 	packages := make([]Package, 0, len(cfgFile.Package))
 	for _, info := range cfgFile.Package {
-		packages = append(packages, Package{
+		pkg := Package{
 			Name:       info.Path,
 			CommitHash: info.Revision,
-		})
+			Version:    info.Version,
+		}
+		// origin copied from a vendor dir of another repo can't be fetched on its own.
+		if !strings.Contains(info.Origin, "/vendor/") {
+			pkg.Source = info.Origin
+		}
+		packages = append(packages, pkg)
 	}
 
 	return packages, nil
 }
 
+// ParseConfig parses vendor.json ignore setting: words with a slash are ignored packages, others are build tags.
+// "test" is not a build tag, it tells govendor to skip test files of vendored packages, which ven vendors
+// only if include_tests is all, so it's skipped.
+func (p GovendorParser) ParseConfig(repoPath string) (*Config, error) {
+	cfgFile := govendorFile{}
+	data, err := ioutil.ReadFile(repoPath + "/vendor/vendor.json")
+	if err != nil {
+		return nil, fmt.Errorf("fail read vendor.json file: %v", err)
+	}
+
+	if err := json.Unmarshal(data, &cfgFile); err != nil {
+		return nil, fmt.Errorf("fail unmarshal vendor.json file: %v", err)
+	}
+
+	cfg := &Config{Constraints: make(map[string]string)}
+	for _, ignore := range strings.Fields(cfgFile.Ignore) {
+		switch {
+		case strings.Contains(ignore, "/"):
+			cfg.Ignore = append(cfg.Ignore, strings.TrimSuffix(ignore, "/"))
+		case ignore != "test":
+			cfg.ExcludeBuild = append(cfg.ExcludeBuild, ignore)
+		}
+	}
+
+	return cfg, nil
+}
+
 func init() {
 	Parsers["govendor"] = GovendorParser{}
 }
@@ -0,0 +1,22 @@
+package parse
+
+import "strings"
+
+// GpmParser parses gpm Godeps file.
+type GpmParser struct{}
+
+// Check checks whether repo uses gpm, godep keeps Godeps dir instead.
+func (p GpmParser) Check(repoPath string) bool {
+	return isFile(repoPath + "/Godeps")
+}
+
+// Parse parses Godeps lines like "import/path version", lines with gpm plugin directives are skipped.
+func (p GpmParser) Parse(repoPath string) ([]Package, error) {
+	return parseLinesFile(repoPath+"/Godeps", func(fields []string) bool {
+		return strings.HasPrefix(fields[0], "$")
+	})
+}
+
+func init() {
+	Parsers["gpm"] = GpmParser{}
+}
@@ -0,0 +1,65 @@
+package parse
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// GvtParser parses gvt manifest file.
+type GvtParser struct{}
+
+// vendorManifestFile represents vendor/manifest file, gvt uses the format of gb vendor plugin.
+type vendorManifestFile struct {
+	Dependencies []struct {
+		ImportPath string `json:"importpath"`
+		Repository string `json:"repository"`
+		VCS        string `json:"vcs"`
+		Revision   string `json:"revision"`
+		Branch     string `json:"branch"`
+		Path       string `json:"path"`
+	} `json:"dependencies"`
+}
+
+// Check checks whether repo uses gvt.
+func (p GvtParser) Check(repoPath string) bool {
+	if _, err := os.Stat(repoPath + "/vendor/manifest"); err == nil {
+		return true
+	}
+
+	return false
+}
+
+// Parse parses gvt manifest file for imports.
+func (p GvtParser) Parse(repoPath string) ([]Package, error) {
+	return parseVendorManifest(repoPath)
+}
+
+func parseVendorManifest(repoPath string) ([]Package, error) {
+	cfgFile := vendorManifestFile{}
+	data, err := ioutil.ReadFile(repoPath + "/vendor/manifest")
+	if err != nil {
+		return nil, fmt.Errorf("fail read vendor/manifest file: %v", err)
+	}
+
+	if err := json.Unmarshal(data, &cfgFile); err != nil {
+		return nil, fmt.Errorf("fail unmarshal vendor/manifest file: %v", err)
+	}
+
+	packages := make([]Package, 0, len(cfgFile.Dependencies))
+	for _, info := range cfgFile.Dependencies {
+		packages = append(packages, Package{
+			Name:       info.ImportPath,
+			CommitHash: info.Revision,
+			Source:     info.Repository,
+			VCS:        info.VCS,
+		})
+	}
+
+	return packages, nil
+}
+
+func init() {
+	Parsers["gvt"] = GvtParser{}
+}
@@ -1,5 +1,13 @@
 package parse
 
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
 // Parser parses repo imports.
 type Parser interface {
 	// Check checks whether this parser is used in a cpecific repo.
@@ -8,11 +16,122 @@ type Parser interface {
 	Parse(repoPath string) ([]Package, error)
 }
 
+// ConfigParser parses project config of a tool, so a project can be migrated to ven.
+type ConfigParser interface {
+	// ParseConfig parses project config in repo.
+	ParseConfig(repoPath string) (*Config, error)
+}
+
+// Config describes project settings of a tool.
+type Config struct {
+	// Constraints maps pkg to a version, a semver range, a branch or a revision.
+	Constraints map[string]string
+	// Ignore lists packages excluded from import.
+	Ignore []string
+	// ExcludeDirs lists project directories not scanned for imports.
+	ExcludeDirs []string
+	// ExcludeBuild lists build tags of ignored files.
+	ExcludeBuild []string
+}
+
 // Package describes import package.
 type Package struct {
 	Name       string
 	CommitHash string
+	// Version is a human-readable version, like a tag, if a tool records it.
+	Version string
+	// Constraint is set instead of CommitHash if a tool allows a semver range, a tag, a branch or a revision of pkg
+	// without pinning it, like ven constraints.
+	Constraint string
+	// Source is a repository url or an import path pkg is fetched from instead of its name, like a fork.
+	Source string
+	// VCS is a version control system command of Source, like git or hg.
+	VCS string
+	// Subpackages lists import paths of pkg subpackages in use.
+	Subpackages []string
 }
 
 // Parsers registers all supported parsers.
 var Parsers = make(map[string]Parser)
+
+// Precedence lists parser names in order they are tried, when a repo has several lock files the first one wins.
+var Precedence = []string{"ven", "gomod", "dep", "glide", "godep", "govendor", "gb", "gvt", "vndr", "gpm", "glock"}
+
+// Names returns names of registered parsers in precedence order,
+// parsers missing in Precedence go last in alphabetical order.
+func Names() []string {
+	names := make([]string, 0, len(Parsers))
+	known := make(map[string]struct{}, len(Precedence))
+	for _, name := range Precedence {
+		known[name] = struct{}{}
+		if _, ok := Parsers[name]; ok {
+			names = append(names, name)
+		}
+	}
+
+	var rest []string
+	for name := range Parsers {
+		if _, ok := known[name]; !ok {
+			rest = append(rest, name)
+		}
+	}
+	sort.Strings(rest)
+
+	return append(names, rest...)
+}
+
+// subpackagePaths converts subpackages relative to pkg into import paths, pkg itself is skipped.
+func subpackagePaths(pkg string, subpkgs []string) []string {
+	var paths []string
+	for _, subpkg := range subpkgs {
+		subpkg = strings.Trim(subpkg, "/")
+		if subpkg == "" || subpkg == "." {
+			continue
+		}
+		paths = append(paths, pkg+"/"+subpkg)
+	}
+
+	return paths
+}
+
+func isFile(path string) bool {
+	f, err := os.Stat(path)
+	return err == nil && f.Mode().IsRegular()
+}
+
+// parseLinesFile parses files where every line starts with import path followed by optional version,
+// empty lines, # comments and lines for which skip returns true are ignored.
+func parseLinesFile(path string, skip func(fields []string) bool) ([]Package, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("fail read %s file: %v", path, err)
+	}
+	defer f.Close()
+
+	var packages []Package
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i != -1 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 || (skip != nil && skip(fields)) {
+			continue
+		}
+
+		pkg := Package{Name: fields[0]}
+		if len(fields) > 1 {
+			pkg.CommitHash = fields[1]
+		}
+		if len(fields) > 2 {
+			pkg.Source = fields[2]
+		}
+		packages = append(packages, pkg)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("fail read %s file: %v", path, err)
+	}
+
+	return packages, nil
+}
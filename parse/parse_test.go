@@ -0,0 +1,255 @@
+package parse
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsers(t *testing.T) {
+	cases := []struct {
+		parser string
+		dir    string
+		want   []Package
+	}{
+		{
+			parser: "ven",
+			dir:    "testdata/ven",
+			want: []Package{
+				{Name: "github.com/labstack/echo", CommitHash: "b338075a0fc6e1a0683dbf03d09b4957a289e26f", Version: "v3.2.1", Subpackages: []string{"github.com/labstack/echo/middleware"}},
+				{Name: "github.com/labstack/gommon", CommitHash: "57409ada9da0f2afad6664c49502f8c50fbd8476", Version: "0.2.1", Subpackages: []string{"github.com/labstack/gommon/color"}},
+				{Name: "github.com/pkg/errors", Constraint: "^0.8.0"},
+			},
+		},
+		{
+			parser: "gomod",
+			dir:    "testdata/gomod",
+			want: []Package{
+				{Name: "github.com/pkg/errors", CommitHash: "v0.8.1", Version: "v0.8.1"},
+				{Name: "github.com/davecgh/go-spew", CommitHash: "v1.1.0", Version: "v1.1.0"},
+				{Name: "golang.org/x/sys", CommitHash: "97732733099d"},
+				{Name: "github.com/go-kit/kit", CommitHash: "a34c1b2e7bb3"},
+				{Name: "github.com/coreos/etcd", CommitHash: "v3.3.13", Version: "v3.3.13"},
+				{Name: "github.com/labstack/echo", CommitHash: "v4.1.6", Version: "v4.1.6"},
+				{Name: "github.com/golang/protobuf"},
+				{Name: "github.com/example/forked", CommitHash: "v1.0.1", Version: "v1.0.1", Source: "github.com/someone/forked"},
+				{Name: "github.com/example/pinned", CommitHash: "v1.2.0", Version: "v1.2.0"},
+				{Name: "github.com/example/both", CommitHash: "v1.0.2", Version: "v1.0.2", Source: "github.com/someone/both"},
+				{Name: "gopkg.in/yaml.v2", CommitHash: "v2.2.2", Version: "v2.2.2"},
+			},
+		},
+		{
+			parser: "dep",
+			dir:    "testdata/dep",
+			want: []Package{
+				{Name: "github.com/pelletier/go-toml", CommitHash: "16398bac157da96aa88f98a2df640c7f32af1da2", Version: "v1.0.1"},
+				{Name: "golang.org/x/sys", CommitHash: "8dbc5d05d6edcc104950cc299a1ce6641235bc86", Subpackages: []string{"golang.org/x/sys/unix"}},
+			},
+		},
+		{
+			parser: "glide",
+			dir:    "testdata/glide",
+			want: []Package{
+				{Name: "github.com/labstack/echo", CommitHash: "b338075a0fc6e1a0683dbf03d09b4957a289e26f", Subpackages: []string{"github.com/labstack/echo/middleware"}},
+				{Name: "github.com/mattn/go-colorable", CommitHash: "5411d3eea5978e6cdc258b30de592b60df6aba96", Source: "https://github.com/mattn/go-colorable"},
+			},
+		},
+		{
+			parser: "godep",
+			dir:    "testdata/godep",
+			want: []Package{
+				{Name: "github.com/gorilla/context", CommitHash: "08b5f424b9271eedf6f9f0ce86cb9396ed337a42", Version: "v1.1-7-g08b5f42"},
+				{Name: "github.com/gorilla/mux", CommitHash: "bcd8bc72b08df0f70df986b97f95590779502d31", Version: "v1.4.0"},
+			},
+		},
+		{
+			parser: "govendor",
+			dir:    "testdata/govendor",
+			want: []Package{
+				{Name: "github.com/dgrijalva/jwt-go", CommitHash: "2268707a8f0843315e2004ee4f1d021dc08baedf"},
+				{Name: "github.com/pkg/errors", CommitHash: "645ef00459ed84a119197bfb8d8205042c6df63d"},
+			},
+		},
+		{
+			parser: "gvt",
+			dir:    "testdata/gvt",
+			want: []Package{
+				{Name: "github.com/pkg/errors", CommitHash: "645ef00459ed84a119197bfb8d8205042c6df63d", Source: "https://github.com/pkg/errors", VCS: "git"},
+				{Name: "golang.org/x/net/context", CommitHash: "f2499483f923065a842d38eb4c7f1927e6fc6e6d", Source: "https://go.googlesource.com/net", VCS: "git"},
+			},
+		},
+		{
+			parser: "gb",
+			dir:    "testdata/gb",
+			want: []Package{
+				{Name: "github.com/constabulary/gb", CommitHash: "8f6b1e8a9e0d4a3b2d6f2a1a1b2c3d4e5f6a7b8c", Source: "https://github.com/constabulary/gb"},
+			},
+		},
+		{
+			parser: "vndr",
+			dir:    "testdata/vndr",
+			want: []Package{
+				{Name: "github.com/sirupsen/logrus", CommitHash: "v1.0.3"},
+				{Name: "github.com/docker/go-units", CommitHash: "9e638d38cf6977a37a8ea0078f3ee75a7cdb2dd1"},
+				{Name: "github.com/opencontainers/runc", CommitHash: "2e7cfe036e2c6dc51ccca6eb7fa3ee6b63976dcd", Source: "https://github.com/someone/runc.git"},
+			},
+		},
+		{
+			parser: "gpm",
+			dir:    "testdata/gpm",
+			want: []Package{
+				{Name: "github.com/nu7hatch/gotrail", CommitHash: "v0.0.2"},
+				{Name: "github.com/replicon/fast-archiver"},
+				{Name: "github.com/codegangsta/cli", CommitHash: "1.2.0"},
+			},
+		},
+		{
+			parser: "glock",
+			dir:    "testdata/glock",
+			want: []Package{
+				{Name: "github.com/robfig/glock", CommitHash: "4a4b4c8d2ed8d8e4a4ad87c9d0b6d3a9a1d6b5e7"},
+				{Name: "github.com/agtorre/gocolorize", CommitHash: "f42b554bf7f006936130c9bb4f971afd2d87f671"},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		p, ok := Parsers[c.parser]
+		if !ok {
+			t.Errorf("parser (%s) is not registered", c.parser)
+			continue
+		}
+		if !p.Check(c.dir) {
+			t.Errorf("%s: Check(%s) = false, want true", c.parser, c.dir)
+			continue
+		}
+		got, err := p.Parse(c.dir)
+		if err != nil {
+			t.Errorf("%s: Parse(%s) error: %v", c.parser, c.dir, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: Parse(%s) = %v, want %v", c.parser, c.dir, got, c.want)
+		}
+	}
+}
+
+func TestParsersCheck(t *testing.T) {
+	cases := []struct {
+		parser string
+		dir    string
+	}{
+		// gvt and gb share manifest format, but only gb projects have src dir.
+		{"gb", "testdata/gvt"},
+		// godep keeps Godeps dir, gpm has Godeps file.
+		{"gpm", "testdata/godep"},
+	}
+
+	for _, c := range cases {
+		if Parsers[c.parser].Check(c.dir) {
+			t.Errorf("%s: Check(%s) = true, want false", c.parser, c.dir)
+		}
+	}
+}
+
+func TestNames(t *testing.T) {
+	names := Names()
+	if len(names) != len(Parsers) {
+		t.Fatalf("Names() = %v, want all %d parsers", names, len(Parsers))
+	}
+	for i, name := range Precedence {
+		if names[i] != name {
+			t.Errorf("Names()[%d] = %s, want %s", i, names[i], name)
+		}
+	}
+}
+
+func TestConfigParsers(t *testing.T) {
+	cases := []struct {
+		parser string
+		dir    string
+		want   *Config
+	}{
+		{
+			parser: "glide",
+			dir:    "testdata/glide",
+			want: &Config{
+				Constraints: map[string]string{"github.com/labstack/echo": "^3.2.1"},
+				Ignore:      []string{"github.com/example/project/internal/gen"},
+				ExcludeDirs: []string{"fixtures"},
+			},
+		},
+		{
+			parser: "dep",
+			dir:    "testdata/dep",
+			want: &Config{
+				Constraints: map[string]string{
+					"github.com/pelletier/go-toml": "^1.0.0",
+					"github.com/pkg/errors":        "645ef00459ed84a119197bfb8d8205042c6df63d",
+					"golang.org/x/sys":             "master",
+					"github.com/labstack/echo":     ">=3.0.0 <4.0.0",
+				},
+				Ignore: []string{"github.com/example/project/internal/gen"},
+			},
+		},
+		{
+			parser: "godep",
+			dir:    "testdata/godep",
+			want:   &Config{Constraints: map[string]string{}},
+		},
+		{
+			parser: "govendor",
+			dir:    "testdata/govendor",
+			want: &Config{
+				Constraints:  map[string]string{},
+				Ignore:       []string{"github.com/example/project/tools"},
+				ExcludeBuild: []string{"appengine"},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		cp, ok := Parsers[c.parser].(ConfigParser)
+		if !ok {
+			t.Errorf("%s: parser does not implement ConfigParser", c.parser)
+			continue
+		}
+		got, err := cp.ParseConfig(c.dir)
+		if err != nil {
+			t.Errorf("%s: ParseConfig() error: %v", c.parser, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: ParseConfig() = %+v, want %+v", c.parser, got, c.want)
+		}
+	}
+}
+
+func Test_depVersionConstraint(t *testing.T) {
+	cases := []struct {
+		version string
+		want    string
+		wantErr bool
+	}{
+		{version: "1.2.0", want: "^1.2.0"},
+		{version: "v1.2", want: "^v1.2"},
+		{version: "=1.2.0", want: "1.2.0"},
+		{version: "~1.2.0", want: "~1.2.0"},
+		{version: ">=1.2.0, <2.0.0", want: ">=1.2.0 <2.0.0"},
+		{version: ">=1.2.0, *", want: ">=1.2.0"},
+		{version: "*", want: ""},
+		{version: "!=1.2.0", wantErr: true},
+		{version: ">=1.0.0, !=1.2.0", wantErr: true},
+		{version: "^1.0.0 || ^2.0.0", wantErr: true},
+	}
+
+	for _, c := range cases {
+		got, err := depVersionConstraint(c.version)
+		if (err != nil) != c.wantErr {
+			t.Errorf("depVersionConstraint(%s) error = %v, wantErr %v", c.version, err, c.wantErr)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("depVersionConstraint(%s) = %q, want %q", c.version, got, c.want)
+		}
+	}
+}
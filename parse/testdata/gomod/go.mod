@@ -0,0 +1,30 @@
+module github.com/example/project
+
+go 1.12
+
+require (
+	github.com/pkg/errors v0.8.1
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	golang.org/x/sys v0.0.0-20190412213103-97732733099d
+	github.com/go-kit/kit v0.9.1-0.20190710201303-a34c1b2e7bb3
+	github.com/coreos/etcd v3.3.13+incompatible
+	github.com/labstack/echo/v4 v4.1.6
+	github.com/golang/protobuf v1.3.1
+	github.com/example/local v1.0.0
+	github.com/example/forked v1.0.0
+	github.com/example/pinned v1.2.0
+	github.com/example/both v1.0.0
+)
+
+require gopkg.in/yaml.v2 v2.2.2
+
+exclude github.com/golang/protobuf v1.3.1
+
+replace (
+	github.com/davecgh/go-spew => github.com/davecgh/go-spew v1.1.0
+	github.com/example/local => ../local
+	github.com/example/forked v1.0.0 => github.com/someone/forked v1.0.1
+	github.com/example/pinned v1.1.0 => github.com/someone/pinned v1.1.1
+	github.com/example/both => github.com/other/both v2.0.0
+	github.com/example/both v1.0.0 => github.com/someone/both v1.0.2
+)
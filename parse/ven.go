@@ -0,0 +1,101 @@
+package parse
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// VenParser parses ven manifest file.
+type VenParser struct{}
+
+// venManifestFile represents ven manifest file.
+type venManifestFile struct {
+	LocalPackages []string `yaml:"local_packages"`
+	Constraints   map[string]string
+	Packages      map[string]struct {
+		Version     string
+		CommitHash  string
+		VCS         string
+		Source      string
+		Subpackages []string
+	}
+}
+
+func readVenManifest(repoPath string) (*venManifestFile, error) {
+	cfgFile := &venManifestFile{}
+	data, err := ioutil.ReadFile(repoPath + "/Manifest.yml")
+	if err != nil {
+		return nil, fmt.Errorf("fail read Manifest.yml file: %v", err)
+	}
+
+	if err := yaml.Unmarshal(data, cfgFile); err != nil {
+		return nil, fmt.Errorf("fail unmarshal Manifest.yml file: %v", err)
+	}
+
+	return cfgFile, nil
+}
+
+// Check checks whether repo uses ven, Manifest.yml of other tools has no ven packages or constraints.
+func (p VenParser) Check(repoPath string) bool {
+	cfgFile, err := readVenManifest(repoPath)
+	if err != nil {
+		return false
+	}
+
+	return len(cfgFile.Packages) != 0 || len(cfgFile.Constraints) != 0
+}
+
+// Parse parses ven manifest for imports: packages are pinned to their commits,
+// constraints are used for packages missing in manifest, local packages are skipped.
+func (p VenParser) Parse(repoPath string) ([]Package, error) {
+	cfgFile, err := readVenManifest(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	local := make(map[string]struct{}, len(cfgFile.LocalPackages))
+	for _, pkg := range cfgFile.LocalPackages {
+		local[pkg] = struct{}{}
+	}
+
+	names := make([]string, 0, len(cfgFile.Packages)+len(cfgFile.Constraints))
+	for name := range cfgFile.Packages {
+		names = append(names, name)
+	}
+	for name := range cfgFile.Constraints {
+		if _, ok := cfgFile.Packages[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	packages := make([]Package, 0, len(names))
+	for _, name := range names {
+		if _, ok := local[name]; ok {
+			continue
+		}
+
+		info, ok := cfgFile.Packages[name]
+		if !ok {
+			packages = append(packages, Package{Name: name, Constraint: cfgFile.Constraints[name]})
+			continue
+		}
+		packages = append(packages, Package{
+			Name:        name,
+			CommitHash:  info.CommitHash,
+			Version:     info.Version,
+			Source:      info.Source,
+			VCS:         info.VCS,
+			Subpackages: info.Subpackages,
+		})
+	}
+
+	return packages, nil
+}
+
+func init() {
+	Parsers["ven"] = VenParser{}
+}
@@ -0,0 +1,18 @@
+package parse
+
+// VndrParser parses vendor.conf file of vndr and trash.
+type VndrParser struct{}
+
+// Check checks whether repo uses vndr or trash.
+func (p VndrParser) Check(repoPath string) bool {
+	return isFile(repoPath + "/vendor.conf")
+}
+
+// Parse parses vendor.conf lines like "import/path revision [repository url]".
+func (p VndrParser) Parse(repoPath string) ([]Package, error) {
+	return parseLinesFile(repoPath+"/vendor.conf", nil)
+}
+
+func init() {
+	Parsers["vndr"] = VndrParser{}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+)
+
+// Prune removes packages and subpackages that are not used by the project anymore.
+func Prune(ctx context.Context, project string, dryRun, verbose bool) error {
+	projectDeps, err := getProjectDeps(project, verbose)
+	if err != nil {
+		return err
+	}
+
+	// entries keeps import paths of every used pkg, they are the starting points for subpackages scan.
+	entries := make(map[string][]string)
+	roots := make([]string, 0, len(projectDeps))
+	for root, imports := range projectDeps {
+		roots = append(roots, root)
+		entries[root] = append(entries[root], imports...)
+	}
+	reachable := manifest.ReachablePkgs(roots, nil)
+	for pkg := range reachable {
+		for _, dep := range manifest.PkgDeps(pkg) {
+			if _, root, exists := manifest.PkgExists(dep); exists {
+				entries[root] = append(entries[root], dep)
+			}
+		}
+	}
+
+	var unusedPkgs []string
+	for pkg := range manifest.Packages {
+		if _, ok := reachable[pkg]; !ok {
+			unusedPkgs = append(unusedPkgs, pkg)
+		}
+	}
+	sort.Strings(unusedPkgs)
+
+	unusedSubpkgs := make(map[string][]string)
+	for pkg := range reachable {
+		if ctxCancelled(ctx) {
+			return ctx.Err()
+		}
+
+		subpkgs, err := getUsedSubpackages(pkg, entries[pkg], verbose)
+		if err != nil {
+			return err
+		}
+		for subpkg := range manifest.Packages[pkg].Subpackages {
+			if _, ok := subpkgs[subpkg]; !ok {
+				unusedSubpkgs[pkg] = append(unusedSubpkgs[pkg], subpkg)
+			}
+		}
+		sort.Strings(unusedSubpkgs[pkg])
+	}
+
+	reachablePkgs := make([]string, 0, len(reachable))
+	for pkg := range reachable {
+		reachablePkgs = append(reachablePkgs, pkg)
+	}
+	sort.Strings(reachablePkgs)
+
+	if dryRun {
+		for _, pkg := range unusedPkgs {
+			fmt.Printf("would remove package %s\n", pkg)
+		}
+		for _, pkg := range reachablePkgs {
+			for _, subpkg := range unusedSubpkgs[pkg] {
+				fmt.Printf("would remove subpackage %s\n", subpkg)
+			}
+		}
+		return nil
+	}
+
+	// files are staged first, so manifest is saved once and vendor is restored if anything fails.
+	dirs := make([]string, 0, len(unusedPkgs))
+	for _, pkg := range unusedPkgs {
+		dirs = append(dirs, fmt.Sprintf("%s/%s", manifest.VendorPath, pkg))
+	}
+	paths := append([]string(nil), dirs...)
+	for _, pkg := range reachablePkgs {
+		for _, subpkg := range unusedSubpkgs[pkg] {
+			files, err := subpackageFiles(subpkg)
+			if err != nil {
+				return fmt.Errorf("pkg (%s): cannot list subpackage (%s) files: %v", pkg, subpkg, err)
+			}
+			paths = append(paths, files...)
+		}
+	}
+
+	stash, err := stashDirs(paths)
+	if err != nil {
+		return fmt.Errorf("cannot remove packages from vendor: %v", err)
+	}
+	if err := prunePkgs(unusedPkgs, reachablePkgs, unusedSubpkgs); err != nil {
+		if restoreErr := stash.Restore(); restoreErr != nil {
+			return fmt.Errorf("%v; cannot restore vendor: %v", err, restoreErr)
+		}
+		return err
+	}
+	if err := stash.Drop(); err != nil {
+		return fmt.Errorf("cannot delete removed packages: %v", err)
+	}
+
+	for _, dir := range dirs {
+		removeEmptyParents(dir, manifest.VendorPath)
+	}
+	for _, pkg := range unusedPkgs {
+		fmt.Printf("removed package %s\n", pkg)
+	}
+	for _, pkg := range reachablePkgs {
+		for _, subpkg := range unusedSubpkgs[pkg] {
+			dir := fmt.Sprintf("%s/%s", manifest.VendorPath, subpkg)
+			if err := os.Remove(dir); err == nil {
+				removeEmptyParents(dir, fmt.Sprintf("%s/%s", manifest.VendorPath, pkg))
+			}
+			fmt.Printf("removed subpackage %s\n", subpkg)
+		}
+	}
+
+	return nil
+}
+
+// prunePkgs removes unused packages with their constraints and local marks from manifest, marks unused subpackages
+// as pruned, so they are not vendored again, updates hashes of pruned packages and saves manifest. Files of removed subpackages have to be deleted already.
+func prunePkgs(unusedPkgs, reachablePkgs []string, unusedSubpkgs map[string][]string) error {
+	for _, pkg := range unusedPkgs {
+		delete(manifest.Packages, pkg)
+		delete(manifest.Constraints, pkg)
+		delete(manifest.LocalPackages, pkg)
+	}
+	for _, pkg := range reachablePkgs {
+		if len(unusedSubpkgs[pkg]) == 0 {
+			continue
+		}
+
+		info := manifest.Packages[pkg]
+		if info.Pruned == nil {
+			info.Pruned = make(map[string]struct{})
+		}
+		for _, subpkg := range unusedSubpkgs[pkg] {
+			delete(info.Subpackages, subpkg)
+			info.Pruned[subpkg] = struct{}{}
+		}
+		hash, err := pkgHash(pkg, info.Pruned)
+		if err != nil {
+			return fmt.Errorf("pkg (%s): cannot compute hash: %v", pkg, err)
+		}
+		info.Hash = hash
+		manifest.Packages[pkg] = info
+	}
+
+	return saveManifest()
+}
+
+// getUsedSubpackages walks vendored pkg starting from imported paths and returns all reached subpackages.
+// Every imported path has to be vendored, otherwise subpackages it uses would look unused.
+func getUsedSubpackages(pkg string, imports []string, verbose bool) (map[string]struct{}, error) {
+	subpkgs := make(map[string]struct{})
+	if len(imports) == 0 {
+		return subpkgs, nil
+	}
+	for _, i := range imports {
+		if _, err := os.Stat(fmt.Sprintf("%s/%s", manifest.VendorPath, i)); err != nil {
+			return nil, fmt.Errorf("pkg (%s): imported subpackage (%s) is not vendored, run install first: %v", pkg, i, err)
+		}
+	}
+
+	_, walked, _, err := getPkgImports(pkg, manifest.Packages[pkg], imports, fmt.Sprintf("%s/%s", manifest.VendorPath, pkg), false, false, false, verbose)
+	if err != nil {
+		return nil, fmt.Errorf("pkg (%s): failed get imports: %v", pkg, err)
+	}
+	for _, subpkg := range walked {
+		subpkgs[subpkg] = struct{}{}
+	}
+
+	return subpkgs, nil
+}
+
+// subpackageFiles returns files of a vendored subpackage, its nested directories are not included.
+func subpackageFiles(subpkg string) ([]string, error) {
+	dir := fmt.Sprintf("%s/%s", manifest.VendorPath, subpkg)
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var paths []string
+	for _, f := range files {
+		if !f.IsDir() {
+			paths = append(paths, dir+"/"+f.Name())
+		}
+	}
+
+	return paths, nil
+}
+
+// removePrunedFiles removes files of pruned pkg subpackages from vendor.
+func removePrunedFiles(pkg string, pruned map[string]struct{}) error {
+	for subpkg := range pruned {
+		files, err := subpackageFiles(subpkg)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			if err := os.Remove(file); err != nil {
+				return err
+			}
+		}
+		dir := fmt.Sprintf("%s/%s", manifest.VendorPath, subpkg)
+		if err := os.Remove(dir); err == nil {
+			removeEmptyParents(dir, fmt.Sprintf("%s/%s", manifest.VendorPath, pkg))
+		}
+	}
+
+	return nil
+}
+
+// hasPrunedSubpkgs checks whether any of subpkgs is pruned from pkg described by info.
+func hasPrunedSubpkgs(info Package, subpkgs []string) bool {
+	for _, subpkg := range subpkgs {
+		if _, ok := info.Pruned[subpkg]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// prunedExcept returns pruned subpackages without subpkgs, nil if none is left.
+func prunedExcept(pruned map[string]struct{}, subpkgs []string) map[string]struct{} {
+	left := make(map[string]struct{}, len(pruned))
+	for subpkg := range pruned {
+		left[subpkg] = struct{}{}
+	}
+	for _, subpkg := range subpkgs {
+		delete(left, subpkg)
+	}
+	if len(left) == 0 {
+		return nil
+	}
+
+	return left
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// setupPruneProject sets up and fetches project importing github.com/fx/c and github.com/fx/a with subpackages x and y,
+// then drops imports of c and y from the project.
+func setupPruneProject(t *testing.T) {
+	dir := setupProject(t)
+	addTestRepo(t, dir, "github.com/fx/a", []testCommit{
+		{Files: map[string]string{
+			"a.go":   "package a\n",
+			"x/x.go": "package x\n",
+			"y/y.go": "package y\n",
+		}},
+	})
+	addTestRepo(t, dir, "github.com/fx/c", []testCommit{
+		{Files: map[string]string{"c.go": "package c\n"}},
+	})
+
+	fetchTestProject(t, "github.com/fx/a", "github.com/fx/a/x", "github.com/fx/a/y", "github.com/fx/c")
+	if _, ok := manifest.Packages["github.com/fx/c"]; !ok {
+		t.Fatal("github.com/fx/c is not fetched")
+	}
+
+	writeMain(t, "github.com/fx/a", "github.com/fx/a/x")
+}
+
+func TestPrune(t *testing.T) {
+	setupPruneProject(t)
+	manifest.Constraints["github.com/fx/c"] = "^1.0.0"
+	manifest.LocalPackages["github.com/fx/c"] = struct{}{}
+	if err := saveManifest(); err != nil {
+		t.Fatal(err)
+	}
+
+	before, err := ioutil.ReadFile("Manifest.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Prune(context.Background(), "example.com/proj", true, false); err != nil {
+		t.Fatalf("Prune() dry run error: %v", err)
+	}
+	if after, _ := ioutil.ReadFile("Manifest.yml"); string(after) != string(before) {
+		t.Errorf("dry run changed manifest:\n%s", after)
+	}
+	if _, err := os.Stat("vendor/github.com/fx/c"); err != nil {
+		t.Errorf("dry run removed github.com/fx/c: %v", err)
+	}
+
+	if err := Prune(context.Background(), "example.com/proj", false, false); err != nil {
+		t.Fatalf("Prune() error: %v", err)
+	}
+
+	if got, want := listFiles(t, "vendor"), []string{"github.com/fx/a/a.go", "github.com/fx/a/x/x.go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("vendor files = %v, want %v", got, want)
+	}
+	resetImportState()
+	saved, err := parseManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := saved.Packages["github.com/fx/c"]; ok {
+		t.Error("github.com/fx/c is kept in manifest")
+	}
+	if _, ok := saved.Constraints["github.com/fx/c"]; ok {
+		t.Error("constraint of github.com/fx/c is kept in manifest")
+	}
+	if _, ok := saved.LocalPackages["github.com/fx/c"]; ok {
+		t.Error("github.com/fx/c is kept in manifest local packages")
+	}
+	a, ok := saved.Packages["github.com/fx/a"]
+	if !ok {
+		t.Fatal("github.com/fx/a is removed from manifest")
+	}
+	if _, ok := a.Subpackages["github.com/fx/a/y"]; ok {
+		t.Error("github.com/fx/a/y is kept in manifest")
+	}
+	if _, ok := a.Pruned["github.com/fx/a/y"]; !ok {
+		t.Errorf("github.com/fx/a/y is not marked as pruned: %v", a.Pruned)
+	}
+	manifest = saved
+	if hash, err := pkgHash("github.com/fx/a", a.Pruned); err != nil || hash != a.Hash {
+		t.Errorf("github.com/fx/a manifest hash = %s, vendor hash %s: %v", a.Hash, hash, err)
+	}
+}
+
+func TestPruneInstall(t *testing.T) {
+	setupPruneProject(t)
+	if err := Prune(context.Background(), "example.com/proj", false, false); err != nil {
+		t.Fatalf("Prune() error: %v", err)
+	}
+
+	if err := reinstallProject(t, 2); err != nil {
+		t.Fatalf("Install() of pruned project error: %v", err)
+	}
+	if got, want := listFiles(t, "vendor"), []string{"github.com/fx/a/a.go", "github.com/fx/a/x/x.go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("installed vendor files = %v, want %v", got, want)
+	}
+	if err := Verify(context.Background(), false); err != nil {
+		t.Errorf("Verify() of installed project error: %v", err)
+	}
+
+	// pruned subpackage is vendored again, once it's imported.
+	writeMain(t, "github.com/fx/a", "github.com/fx/a/x", "github.com/fx/a/y")
+	if _, err := Get(context.Background(), []string{"github.com/fx/a/y"}, false, false, false, false); err != nil {
+		t.Fatalf("Get() of pruned subpackage error: %v", err)
+	}
+	if got, want := listFiles(t, "vendor"), []string{"github.com/fx/a/a.go", "github.com/fx/a/x/x.go", "github.com/fx/a/y/y.go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("vendor files = %v, want %v", got, want)
+	}
+	a := manifest.Packages["github.com/fx/a"]
+	if _, ok := a.Subpackages["github.com/fx/a/y"]; !ok || len(a.Pruned) != 0 {
+		t.Errorf("github.com/fx/a subpackages = %v, pruned = %v, want github.com/fx/a/y unpruned", a.Subpackages, a.Pruned)
+	}
+	if err := reinstallProject(t, 2); err != nil {
+		t.Fatalf("Install() after get of pruned subpackage error: %v", err)
+	}
+	if err := Verify(context.Background(), false); err != nil {
+		t.Errorf("Verify() after get of pruned subpackage error: %v", err)
+	}
+}
+
+func TestPruneMissingSubpackage(t *testing.T) {
+	setupPruneProject(t)
+
+	if err := os.RemoveAll("vendor/github.com/fx/a/x"); err != nil {
+		t.Fatal(err)
+	}
+	before, err := ioutil.ReadFile("Manifest.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Prune(context.Background(), "example.com/proj", false, false); err == nil {
+		t.Fatal("Prune() with missing imported subpackage succeeded")
+	}
+	if after, _ := ioutil.ReadFile("Manifest.yml"); string(after) != string(before) {
+		t.Errorf("failed prune changed manifest:\n%s", after)
+	}
+	if _, err := os.Stat("vendor/github.com/fx/c"); err != nil {
+		t.Errorf("failed prune removed github.com/fx/c: %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Remove removes packages from manifest and vendor along with their orphaned dependencies.
+func Remove(ctx context.Context, project string, pkgs []string, verbose bool) error {
+	removed := make(map[string]struct{})
+	roots := make([]string, 0, len(pkgs))
+	for _, pkg := range pkgs {
+		_, root, exists := manifest.PkgExists(pkg)
+		if !exists {
+			return fmt.Errorf("pkg (%s): not found in manifest", pkg)
+		}
+		if _, ok := removed[root]; ok {
+			continue
+		}
+		removed[root] = struct{}{}
+		roots = append(roots, root)
+	}
+
+	projectDeps, err := getProjectDeps(project, verbose)
+	if err != nil {
+		return err
+	}
+	for _, root := range roots {
+		if imports, ok := projectDeps[root]; ok {
+			return fmt.Errorf("pkg (%s): still imported by project (%s)", root, strings.Join(imports, ", "))
+		}
+	}
+	if ctxCancelled(ctx) {
+		return ctx.Err()
+	}
+
+	projectRoots := make([]string, 0, len(projectDeps))
+	for root := range projectDeps {
+		projectRoots = append(projectRoots, root)
+	}
+	used := manifest.ReachablePkgs(projectRoots, removed)
+
+	// dependencies of removed pkgs become orphaned once nothing else reaches them.
+	var toRemove []string
+	for pkg := range manifest.ReachablePkgs(roots, nil) {
+		if _, ok := used[pkg]; !ok {
+			toRemove = append(toRemove, pkg)
+		}
+	}
+	sort.Strings(toRemove)
+
+	dirs := make([]string, 0, len(toRemove))
+	for _, pkg := range toRemove {
+		if _, explicit := removed[pkg]; !explicit && verbose {
+			fmt.Printf("pkg (%s): orphaned, removing\n", pkg)
+		}
+		delete(manifest.Packages, pkg)
+		dirs = append(dirs, fmt.Sprintf("%s/%s", manifest.VendorPath, pkg))
+	}
+	for _, root := range roots {
+		delete(manifest.Constraints, root)
+		delete(manifest.LocalPackages, root)
+	}
+
+	stash, err := stashDirs(dirs)
+	if err != nil {
+		return fmt.Errorf("cannot remove packages from vendor: %v", err)
+	}
+	if err := saveManifest(); err != nil {
+		if restoreErr := stash.Restore(); restoreErr != nil {
+			return fmt.Errorf("%v; cannot restore vendor: %v", err, restoreErr)
+		}
+		return err
+	}
+	if err := stash.Drop(); err != nil {
+		return fmt.Errorf("cannot delete removed packages: %v", err)
+	}
+	for _, dir := range dirs {
+		removeEmptyParents(dir, manifest.VendorPath)
+	}
+
+	for _, pkg := range toRemove {
+		fmt.Printf("removed %s\n", pkg)
+	}
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// setupRemoveProject sets up and fetches project importing github.com/fx/a and github.com/fx/b, both depend on github.com/fx/s,
+// github.com/fx/o is a dependency of a only. Then drops import of a from the project.
+func setupRemoveProject(t *testing.T) {
+	dir := setupProject(t)
+	addTestRepo(t, dir, "github.com/fx/a", []testCommit{
+		{Files: map[string]string{"a.go": "package a\n\nimport (\n\t_ \"github.com/fx/o\"\n\t_ \"github.com/fx/s\"\n)\n"}},
+	})
+	addTestRepo(t, dir, "github.com/fx/b", []testCommit{
+		{Files: map[string]string{"b.go": "package b\n\nimport _ \"github.com/fx/s\"\n"}},
+	})
+	addTestRepo(t, dir, "github.com/fx/o", []testCommit{
+		{Files: map[string]string{"o.go": "package o\n"}},
+	})
+	addTestRepo(t, dir, "github.com/fx/s", []testCommit{
+		{Files: map[string]string{"s.go": "package s\n"}},
+	})
+
+	fetchTestProject(t, "github.com/fx/a", "github.com/fx/b")
+	writeMain(t, "github.com/fx/b")
+}
+
+func TestRemove(t *testing.T) {
+	setupRemoveProject(t)
+
+	err := Remove(context.Background(), "example.com/proj", []string{"github.com/fx/b"}, false)
+	if err == nil || !strings.Contains(err.Error(), "still imported by project") {
+		t.Errorf("Remove() of imported pkg error = %v, want still imported", err)
+	}
+
+	out := captureStdout(t, func() {
+		err = Remove(context.Background(), "example.com/proj", []string{"github.com/fx/a"}, false)
+	})
+	if err != nil {
+		t.Fatalf("Remove() error: %v", err)
+	}
+	if want := "removed github.com/fx/a\nremoved github.com/fx/o\n"; out != want {
+		t.Errorf("Remove() output = %q, want %q", out, want)
+	}
+
+	if got, want := listFiles(t, "vendor"), []string{"github.com/fx/b/b.go", "github.com/fx/s/s.go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("vendor files = %v, want %v", got, want)
+	}
+	resetImportState()
+	saved, err := parseManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pkgs []string
+	for pkg := range saved.Packages {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Strings(pkgs)
+	if want := []string{"github.com/fx/b", "github.com/fx/s"}; !reflect.DeepEqual(pkgs, want) {
+		t.Errorf("manifest packages = %v, want %v", pkgs, want)
+	}
+}
+
+func TestRemoveRestoresVendor(t *testing.T) {
+	setupRemoveProject(t)
+
+	before, err := ioutil.ReadFile("Manifest.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vendorBefore := listFiles(t, "vendor")
+	// manifest backup can't be made over a non-empty dir, so saving manifest fails.
+	writeFile(t, "Manifest.orig.yml/keep", "")
+
+	if err := Remove(context.Background(), "example.com/proj", []string{"github.com/fx/a"}, false); err == nil {
+		t.Fatal("Remove() with failing manifest save succeeded")
+	}
+	if after, _ := ioutil.ReadFile("Manifest.yml"); string(after) != string(before) {
+		t.Errorf("failed remove changed manifest:\n%s", after)
+	}
+	if got := listFiles(t, "vendor"); !reflect.DeepEqual(got, vendorBefore) {
+		t.Errorf("vendor files after failed remove = %v, want %v", got, vendorBefore)
+	}
+	if stashed, _ := filepath.Glob(".ven-removed*"); len(stashed) != 0 {
+		t.Errorf("stash dirs are left: %v", stashed)
+	}
+}
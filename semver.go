@@ -0,0 +1,271 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// semver describes semantic version parsed from a tag.
+type semver struct {
+	Major, Minor, Patch int
+	Pre                 string
+}
+
+// parseSemver parses tags like v1.2.3, 1.2 or v1.2.3-rc.1. A single number is a semver only with v prefix,
+// so tags like 2019 are not taken for versions.
+func parseSemver(tag string) (semver, bool) {
+	sv, parts, ok := parsePartialSemver(tag)
+	if !ok || parts == 1 && !strings.HasPrefix(tag, "v") {
+		return semver{}, false
+	}
+
+	return sv, true
+}
+
+// parsePartialSemver parses semver allowing missing minor and patch, returns number of specified parts.
+func parsePartialSemver(ver string) (semver, int, bool) {
+	v := strings.TrimPrefix(ver, "v")
+	if i := strings.Index(v, "+"); i != -1 {
+		v = v[:i]
+	}
+
+	var sv semver
+	if i := strings.Index(v, "-"); i != -1 {
+		v, sv.Pre = v[:i], v[i+1:]
+	}
+
+	parts := strings.Split(v, ".")
+	if len(parts) == 0 || len(parts) > 3 {
+		return semver{}, 0, false
+	}
+	nums := make([]int, 3)
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return semver{}, 0, false
+		}
+		nums[i] = n
+	}
+	sv.Major, sv.Minor, sv.Patch = nums[0], nums[1], nums[2]
+
+	return sv, len(parts), true
+}
+
+// Compare returns -1, 0 or 1 if v is lower, equal or greater than other.
+func (v semver) Compare(other semver) int {
+	switch {
+	case v.Major != other.Major:
+		return cmpInt(v.Major, other.Major)
+	case v.Minor != other.Minor:
+		return cmpInt(v.Minor, other.Minor)
+	case v.Patch != other.Patch:
+		return cmpInt(v.Patch, other.Patch)
+	case v.Pre == other.Pre:
+		return 0
+	case v.Pre == "":
+		return 1
+	case other.Pre == "":
+		return -1
+	default:
+		return comparePre(v.Pre, other.Pre)
+	}
+}
+
+// comparePre compares pre-release versions by their dot separated identifiers: numeric identifiers are compared
+// numerically and are lower than alphanumeric ones, a shorter list of equal identifiers is lower.
+func comparePre(a, b string) int {
+	aIDs, bIDs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(aIDs) && i < len(bIDs); i++ {
+		aNum, bNum := isNumericID(aIDs[i]), isNumericID(bIDs[i])
+		switch {
+		case aNum && bNum:
+			// numbers are compared by length first, so big ones don't overflow.
+			x, y := strings.TrimLeft(aIDs[i], "0"), strings.TrimLeft(bIDs[i], "0")
+			if len(x) != len(y) {
+				return cmpInt(len(x), len(y))
+			}
+			if x != y {
+				return strings.Compare(x, y)
+			}
+		case aNum:
+			return -1
+		case bNum:
+			return 1
+		case aIDs[i] != bIDs[i]:
+			return strings.Compare(aIDs[i], bIDs[i])
+		}
+	}
+
+	return cmpInt(len(aIDs), len(bIDs))
+}
+
+func isNumericID(id string) bool {
+	if id == "" {
+		return false
+	}
+	for _, c := range id {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func cmpInt(a, b int) int {
+	if a < b {
+		return -1
+	}
+	if a > b {
+		return 1
+	}
+
+	return 0
+}
+
+// latestTag returns the highest semver tag allowed by the filter, nil filter allows all tags.
+func latestTag(tags []string, allow func(tag string) bool) string {
+	var (
+		latest   string
+		latestSv semver
+	)
+	for _, tag := range tags {
+		sv, ok := parseSemver(tag)
+		if !ok || (allow != nil && !allow(tag)) {
+			continue
+		}
+		if latest == "" || sv.Compare(latestSv) > 0 {
+			latest, latestSv = tag, sv
+		}
+	}
+
+	return latest
+}
+
+// constraintAllows checks whether tag satisfies pkg constraint,
+// which is either a semver range or an exact tag, branch or commit.
+func constraintAllows(constraint, tag string) bool {
+	if !isSemverRange(constraint) {
+		return constraint == tag
+	}
+	r, err := parseSemverRange(constraint)
+	if err != nil {
+		return false
+	}
+
+	return r.Allows(tag)
+}
+
+// semverBound is a single comparison of a semver range, like ">=1.2.0".
+type semverBound struct {
+	Op string
+	V  semver
+}
+
+func (b semverBound) allows(v semver) bool {
+	c := v.Compare(b.V)
+	switch b.Op {
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	default:
+		return c == 0
+	}
+}
+
+// semverRange is a set of bounds a version must satisfy all together.
+type semverRange []semverBound
+
+// Allows checks whether tag is a semver version within the range.
+// Pre-release tags are allowed only if some bound has a pre-release of the same version.
+func (r semverRange) Allows(tag string) bool {
+	v, ok := parseSemver(tag)
+	if !ok {
+		return false
+	}
+
+	preAllowed := v.Pre == ""
+	for _, b := range r {
+		if !b.allows(v) {
+			return false
+		}
+		if b.V.Pre != "" && b.V.Major == v.Major && b.V.Minor == v.Minor && b.V.Patch == v.Patch {
+			preAllowed = true
+		}
+	}
+
+	return preAllowed
+}
+
+// isSemverRange checks whether constraint uses range syntax: ^1.2.0, ~2.3, >=1.4 <2.
+func isSemverRange(constraint string) bool {
+	constraint = strings.TrimSpace(constraint)
+	if constraint == "" {
+		return false
+	}
+
+	return strings.ContainsAny(constraint[:1], "^~<>=") || strings.Contains(constraint, " ")
+}
+
+// parseSemverRange parses space separated range terms, every term has to be satisfied.
+func parseSemverRange(constraint string) (semverRange, error) {
+	var r semverRange
+	for _, term := range strings.Fields(constraint) {
+		op := strings.TrimRightFunc(term, func(c rune) bool {
+			return !strings.ContainsRune("^~<>=", c)
+		})
+		ver := term[len(op):]
+		if ver == "" {
+			return nil, fmt.Errorf("invalid range term %q: no version", term)
+		}
+		v, parts, ok := parsePartialSemver(ver)
+		if !ok {
+			return nil, fmt.Errorf("invalid range term %q: %s is not a semver", term, ver)
+		}
+
+		switch op {
+		case "^":
+			upper := semver{Major: v.Major + 1}
+			switch {
+			case v.Major != 0 || parts == 1:
+			case v.Minor != 0 || parts == 2:
+				upper = semver{Minor: v.Minor + 1}
+			default:
+				upper = semver{Patch: v.Patch + 1}
+			}
+			r = append(r, semverBound{">=", v}, semverBound{"<", upper})
+		case "~":
+			upper := semver{Major: v.Major, Minor: v.Minor + 1}
+			if parts == 1 {
+				upper = semver{Major: v.Major + 1}
+			}
+			r = append(r, semverBound{">=", v}, semverBound{"<", upper})
+		case ">", ">=", "<", "<=", "=", "":
+			r = append(r, semverBound{op, v})
+		default:
+			return nil, fmt.Errorf("invalid range term %q: unknown operator %s", term, op)
+		}
+	}
+
+	return r, nil
+}
+
+// resolveSemverRange returns the highest tag satisfying constraint.
+func resolveSemverRange(constraint string, tags []string) (string, error) {
+	r, err := parseSemverRange(constraint)
+	if err != nil {
+		return "", err
+	}
+	tag := latestTag(tags, r.Allows)
+	if tag == "" {
+		return "", fmt.Errorf("no tag satisfies %s", constraint)
+	}
+
+	return tag, nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import "testing"
+
+func Test_resolveSemverRange(t *testing.T) {
+	tags := []string{"v0.1.0", "v0.1.5", "v0.2.0", "v1.2.0", "v1.2.7", "v1.3.0", "v1.4.0-rc.1", "v2.0.0", "v2.3.1", "v2.4.0", "latest"}
+
+	cases := []struct {
+		constraint, want string
+	}{
+		{"^1.2.0", "v1.3.0"},
+		{"^1.2", "v1.3.0"},
+		{"^0.1.0", "v0.1.5"},
+		{"~1.2", "v1.2.7"},
+		{"~2.3", "v2.3.1"},
+		{"~1", "v1.3.0"},
+		{">=1.4 <2", ""},
+		{">=1.4.0-rc.1 <2", "v1.4.0-rc.1"},
+		{">=1.2.1 <=2.0.0", "v2.0.0"},
+		{">2", "v2.4.0"},
+		{"=v1.2.0", "v1.2.0"},
+	}
+	for _, c := range cases {
+		got, err := resolveSemverRange(c.constraint, tags)
+		if c.want == "" {
+			if err == nil {
+				t.Errorf("resolveSemverRange(%q) = %s, want error", c.constraint, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("resolveSemverRange(%q) error: %v", c.constraint, err)
+		} else if got != c.want {
+			t.Errorf("resolveSemverRange(%q) = %s, want %s", c.constraint, got, c.want)
+		}
+	}
+
+	if constraintAllows("v1.2.0", "v1.2.7") || !constraintAllows("v1.2.0", "v1.2.0") {
+		t.Error("constraintAllows() must compare exact constraints literally")
+	}
+}
+
+func Test_parseSemver(t *testing.T) {
+	cases := []struct {
+		tag  string
+		want semver
+		ok   bool
+	}{
+		{"v1.2.3", semver{Major: 1, Minor: 2, Patch: 3}, true},
+		{"1.2", semver{Major: 1, Minor: 2}, true},
+		{"v2", semver{Major: 2}, true},
+		{"v1.2.3-rc.1+build.5", semver{Major: 1, Minor: 2, Patch: 3, Pre: "rc.1"}, true},
+		{"2019", semver{}, false},
+		{"1", semver{}, false},
+		{"v1.2.3.4", semver{}, false},
+		{"latest", semver{}, false},
+	}
+	for _, c := range cases {
+		got, ok := parseSemver(c.tag)
+		if ok != c.ok || got != c.want {
+			t.Errorf("parseSemver(%q) = %+v, %v, want %+v, %v", c.tag, got, ok, c.want, c.ok)
+		}
+	}
+}
+
+func Test_semverCompare(t *testing.T) {
+	// every version is lower than the next one, as in semver spec precedence example.
+	ordered := []string{
+		"v1.0.0-alpha",
+		"v1.0.0-alpha.1",
+		"v1.0.0-alpha.beta",
+		"v1.0.0-beta",
+		"v1.0.0-beta.2",
+		"v1.0.0-beta.11",
+		"v1.0.0-rc.1",
+		"v1.0.0",
+		"v1.0.1",
+		"v1.1.0",
+		"v2.0.0",
+	}
+	for i, a := range ordered {
+		for j, b := range ordered {
+			va, _ := parseSemver(a)
+			vb, _ := parseSemver(b)
+			want := cmpInt(i, j)
+			if got := va.Compare(vb); got != want {
+				t.Errorf("%s.Compare(%s) = %d, want %d", a, b, got, want)
+			}
+		}
+	}
+
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"v1.0.0-2", "v1.0.0-10", -1},
+		{"v1.0.0-99999999999999999999", "v1.0.0-100000000000000000000", -1},
+		{"v1.0.0-1", "v1.0.0-a", -1},
+		{"v1.0.0-rc.1+build.2", "v1.0.0-rc.1", 0},
+	}
+	for _, c := range cases {
+		va, _ := parseSemver(c.a)
+		vb, _ := parseSemver(c.b)
+		if got := va.Compare(vb); got != c.want {
+			t.Errorf("%s.Compare(%s) = %d, want %d", c.a, c.b, got, c.want)
+		}
+	}
+}
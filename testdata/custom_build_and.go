@@ -0,0 +1,11 @@
+// +build linux,386
+
+package some
+
+import (
+	"encoding"
+
+	_ "github.com/asaskevich/and"
+)
+
+var _ encoding.TextMarshaler
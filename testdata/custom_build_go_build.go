@@ -0,0 +1,12 @@
+//go:build linux && !windows
+// +build windows
+
+package some
+
+import (
+	"encoding"
+
+	_ "github.com/asaskevich/gobuildlinux"
+)
+
+var _ encoding.TextMarshaler
@@ -0,0 +1,11 @@
+//go:build windows || (linux && 386)
+
+package some
+
+import (
+	"encoding"
+
+	_ "github.com/asaskevich/gobuild"
+)
+
+var _ encoding.TextMarshaler
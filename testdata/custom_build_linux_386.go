@@ -0,0 +1,3 @@
+package some
+
+import _ "github.com/asaskevich/linux386"
@@ -0,0 +1,7 @@
+//go:build !cgo
+
+package some
+
+import (
+	_ "github.com/asaskevich/nocgo"
+)
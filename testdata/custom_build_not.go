@@ -0,0 +1,11 @@
+// +build !windows,!go1.2
+
+package some
+
+import (
+	"encoding"
+
+	_ "github.com/asaskevich/notwindows"
+)
+
+var _ encoding.TextMarshaler
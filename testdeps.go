@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+)
+
+// include_tests settings decide whose test imports are vendored.
+const (
+	// testsNone skips all test files.
+	testsNone = "none"
+	// testsProject vendors packages imported by the project tests.
+	testsProject = "project"
+	// testsAll vendors packages imported by tests of the project and of vendored packages,
+	// test files of vendored packages are kept.
+	testsAll = "all"
+)
+
+// includeProjectTests checks whether project test imports are vendored.
+func includeProjectTests() bool {
+	return manifest.IncludeTests == testsProject || manifest.IncludeTests == testsAll
+}
+
+// includePkgTests checks whether test files and test imports of vendored packages are kept.
+func includePkgTests() bool {
+	return manifest.IncludeTests == testsAll
+}
+
+// getPkgTestImports scans _test.go files of pkg in dirs, returns sorted imports and imports grouped by root packages.
+func getPkgTestImports(pkg string, dirs []string, scanAll, parseMain, verbose bool) ([]string, map[string][]string, error) {
+	importsMap := make(map[string]struct{})
+	for _, dir := range dirs {
+		if _, err := walkImports(pkg, Package{}, dir, scanAll, true, importsMap, parseMain, verbose); err != nil {
+			return nil, nil, err
+		}
+	}
+	imports, depsMap := groupImports(importsMap)
+
+	return imports, depsMap, nil
+}
+
+// getProjectImports scans project sources for imports. Returns imported root packages mapped to used subpackages,
+// separately for non-test and test files. Test files are scanned only if manifest includes project tests.
+func getProjectImports(pkg string, verbose bool) (deps, testDeps map[string][]string, err error) {
+	_, _, deps, err = getPkgImports(pkg, Package{}, nil, "./", true, true, true, verbose)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed get imports for a project: %v", err)
+	}
+	if !includeProjectTests() {
+		return deps, nil, nil
+	}
+
+	_, testDeps, err = getPkgTestImports(pkg, []string{"./"}, true, true, verbose)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed get test imports for a project: %v", err)
+	}
+
+	return deps, testDeps, nil
+}
+
+// mergeDeps adds imports of src to dst, skipping ones dst already has.
+func mergeDeps(dst, src map[string][]string) {
+	for root, imports := range src {
+		seen := make(map[string]struct{})
+		for _, i := range dst[root] {
+			seen[i] = struct{}{}
+		}
+		for _, i := range imports {
+			if _, ok := seen[i]; !ok {
+				seen[i] = struct{}{}
+				dst[root] = append(dst[root], i)
+			}
+		}
+	}
+}
+
+// markTestPkgs marks manifest packages which can't be reached from project non-test imports through pkg deps as test only.
+func markTestPkgs(deps map[string][]string) {
+	queue := make([]string, 0, len(deps))
+	for root := range deps {
+		if _, existingRoot, exists := manifest.PkgExists(root); exists {
+			queue = append(queue, existingRoot)
+		}
+	}
+
+	used := make(map[string]struct{})
+	for len(queue) != 0 {
+		pkg := queue[0]
+		queue = queue[1:]
+		if _, ok := used[pkg]; ok {
+			continue
+		}
+		used[pkg] = struct{}{}
+
+		for dep := range manifest.Packages[pkg].Deps {
+			if _, root, exists := manifest.PkgExists(dep); exists && root != pkg {
+				queue = append(queue, root)
+			}
+		}
+	}
+
+	for name, info := range manifest.Packages {
+		_, ok := used[name]
+		info.Test = !ok
+		manifest.Packages[name] = info
+	}
+}
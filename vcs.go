@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// vcsDriver performs repository operations of a version control system.
+type vcsDriver interface {
+	// Clone clones repo of pkg into dir.
+	Clone(ctx context.Context, pkg, repo, dir string) error
+	// Checkout switches repo in dir to version (tag, branch or revision).
+	Checkout(ctx context.Context, dir, version string) error
+	// Revision returns current revision of repo in dir.
+	Revision(ctx context.Context, dir string) (string, error)
+	// Describe returns the latest tag of current revision.
+	Describe(ctx context.Context, dir string) (string, error)
+	// Tags lists all tags of repo in dir.
+	Tags(ctx context.Context, dir string) ([]string, error)
+	// RevisionTime returns commit time of rev.
+	RevisionTime(ctx context.Context, dir, rev string) (time.Time, error)
+}
+
+// vcsDrivers registers supported version control systems by their commands.
+var vcsDrivers = map[string]vcsDriver{
+	"git": gitDriver{},
+	"hg":  hgDriver{},
+	"bzr": bzrDriver{},
+	"svn": svnDriver{},
+}
+
+// gitDriver works with git repos, clones are made from the repository cache.
+type gitDriver struct{}
+
+func (gitDriver) Clone(ctx context.Context, pkg, repo, dir string) error {
+	mirror, err := syncCache(ctx, pkg, repo)
+	if err != nil {
+		return err
+	}
+
+	_, err = runGit(ctx, "", "clone", "--quiet", mirror, dir)
+	return err
+}
+
+func (gitDriver) Checkout(ctx context.Context, dir, version string) error {
+	_, err := runGit(ctx, dir, "checkout", "--quiet", version)
+	return err
+}
+
+func (gitDriver) Revision(ctx context.Context, dir string) (string, error) {
+	out, err := runGit(ctx, dir, "rev-parse", "HEAD")
+	return strings.TrimSpace(out), err
+}
+
+func (gitDriver) Describe(ctx context.Context, dir string) (string, error) {
+	out, err := runGit(ctx, dir, "describe", "--tags", "--abbrev=0")
+	return strings.TrimSpace(out), err
+}
+
+func (gitDriver) Tags(ctx context.Context, dir string) ([]string, error) {
+	out, err := runGit(ctx, dir, "tag", "--list")
+	return strings.Fields(out), err
+}
+
+func (gitDriver) RevisionTime(ctx context.Context, dir, rev string) (time.Time, error) {
+	out, err := runGit(ctx, dir, "log", "-1", "--format=%ct", rev, "--")
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return parseUnixTime(out)
+}
+
+// hgDriver works with mercurial repos.
+type hgDriver struct{}
+
+func (hgDriver) Clone(ctx context.Context, pkg, repo, dir string) error {
+	_, err := runCmd(ctx, "", "hg", "clone", "--quiet", repo, dir)
+	return err
+}
+
+func (hgDriver) Checkout(ctx context.Context, dir, version string) error {
+	_, err := runCmd(ctx, dir, "hg", "update", "--quiet", "--rev", version)
+	return err
+}
+
+func (hgDriver) Revision(ctx context.Context, dir string) (string, error) {
+	out, err := runCmd(ctx, dir, "hg", "log", "--rev", ".", "--template", "{node}")
+	return strings.TrimSpace(out), err
+}
+
+func (hgDriver) Describe(ctx context.Context, dir string) (string, error) {
+	out, err := runCmd(ctx, dir, "hg", "log", "--rev", ".", "--template", "{latesttag}")
+	if err != nil {
+		return "", err
+	}
+	tag := strings.TrimSpace(out)
+	if tag == "null" {
+		return "", fmt.Errorf("no tags found")
+	}
+
+	return tag, nil
+}
+
+func (hgDriver) Tags(ctx context.Context, dir string) ([]string, error) {
+	out, err := runCmd(ctx, dir, "hg", "tags", "--quiet")
+	return strings.Fields(out), err
+}
+
+func (hgDriver) RevisionTime(ctx context.Context, dir, rev string) (time.Time, error) {
+	// hgdate is "<unix time> <tz offset>"
+	out, err := runCmd(ctx, dir, "hg", "log", "--rev", rev, "--template", "{date|hgdate}")
+	if err != nil {
+		return time.Time{}, err
+	}
+	fields := strings.Fields(out)
+	if len(fields) == 0 {
+		return time.Time{}, fmt.Errorf("unknown revision %s", rev)
+	}
+
+	return parseUnixTime(fields[0])
+}
+
+// bzrDriver works with bazaar repos.
+type bzrDriver struct{}
+
+func (bzrDriver) Clone(ctx context.Context, pkg, repo, dir string) error {
+	_, err := runCmd(ctx, "", "bzr", "branch", "--quiet", repo, dir)
+	return err
+}
+
+func (bzrDriver) Checkout(ctx context.Context, dir, version string) error {
+	_, err := runCmd(ctx, dir, "bzr", "update", "--quiet", "--revision", bzrRevisionSpec(version))
+	return err
+}
+
+func (bzrDriver) Revision(ctx context.Context, dir string) (string, error) {
+	out, err := runCmd(ctx, dir, "bzr", "version-info", "--custom", "--template={revision_id}")
+	return strings.TrimSpace(out), err
+}
+
+func (bzrDriver) Describe(ctx context.Context, dir string) (string, error) {
+	revno, err := runCmd(ctx, dir, "bzr", "revno", "--tree")
+	if err != nil {
+		return "", err
+	}
+	// only tags up to the current revision are listed, the last one is the latest.
+	out, err := runCmd(ctx, dir, "bzr", "tags", "--sort=time", "--revision", "..revno:"+strings.TrimSpace(revno))
+	if err != nil {
+		return "", err
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	fields := strings.Fields(lines[len(lines)-1])
+	if len(fields) == 0 {
+		return "", fmt.Errorf("no tags found")
+	}
+
+	return fields[0], nil
+}
+
+func (bzrDriver) Tags(ctx context.Context, dir string) ([]string, error) {
+	out, err := runCmd(ctx, dir, "bzr", "tags")
+	if err != nil {
+		return nil, err
+	}
+	var tags []string
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if fields := strings.Fields(line); len(fields) != 0 {
+			tags = append(tags, fields[0])
+		}
+	}
+
+	return tags, nil
+}
+
+func (bzrDriver) RevisionTime(ctx context.Context, dir, rev string) (time.Time, error) {
+	out, err := runCmd(ctx, dir, "bzr", "log", "--timezone=utc", "--revision", bzrRevisionSpec(rev))
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, line := range strings.Split(out, "\n") {
+		if ts := strings.TrimPrefix(strings.TrimSpace(line), "timestamp: "); ts != strings.TrimSpace(line) {
+			return time.Parse("Mon 2006-01-02 15:04:05 -0700", ts)
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("no timestamp of revision %s", rev)
+}
+
+// bzrRevisionSpec converts version into bzr revision spec: revision ids recorded by ven look like
+// "user@host-20170101000000-abcdef", other versions are treated as tags.
+func bzrRevisionSpec(version string) string {
+	switch {
+	case strings.Contains(version, ":"):
+		return version
+	case strings.Contains(version, "@"):
+		return "revid:" + version
+	default:
+		return "tag:" + version
+	}
+}
+
+// svnDriver works with subversion repos.
+type svnDriver struct{}
+
+func (svnDriver) Clone(ctx context.Context, pkg, repo, dir string) error {
+	_, err := runCmd(ctx, "", "svn", "checkout", "--quiet", repo, dir)
+	return err
+}
+
+func (svnDriver) Checkout(ctx context.Context, dir, version string) error {
+	_, err := runCmd(ctx, dir, "svn", "update", "--quiet", "--revision", version)
+	return err
+}
+
+func (svnDriver) Revision(ctx context.Context, dir string) (string, error) {
+	out, err := runCmd(ctx, dir, "svn", "info", "--show-item", "revision")
+	return strings.TrimSpace(out), err
+}
+
+// Describe is not supported, svn tags are just directories.
+func (svnDriver) Describe(ctx context.Context, dir string) (string, error) {
+	return "", fmt.Errorf("svn has no tags")
+}
+
+// Tags is not supported, so semver ranges can't be used for svn repos.
+func (svnDriver) Tags(ctx context.Context, dir string) ([]string, error) {
+	return nil, fmt.Errorf("svn has no tags")
+}
+
+func (svnDriver) RevisionTime(ctx context.Context, dir, rev string) (time.Time, error) {
+	out, err := runCmd(ctx, dir, "svn", "info", "--revision", rev, "--show-item", "last-changed-date")
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Parse(time.RFC3339Nano, strings.TrimSpace(out))
+}
+
+// newestRevision returns the most recently committed of revs.
+func newestRevision(ctx context.Context, driver vcsDriver, dir string, revs []string) (string, error) {
+	var (
+		newest     string
+		newestTime time.Time
+	)
+	for _, rev := range revs {
+		t, err := driver.RevisionTime(ctx, dir, rev)
+		if err != nil {
+			return "", fmt.Errorf("revision %s: %v", rev, err)
+		}
+		if newest == "" || t.After(newestTime) {
+			newest, newestTime = rev, t
+		}
+	}
+
+	return newest, nil
+}
+
+func parseUnixTime(s string) (time.Time, error) {
+	sec, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid unix time %q", s)
+	}
+
+	return time.Unix(sec, 0), nil
+}
+
+// vcsName returns vcs command recorded in manifest, where empty means git.
+func vcsName(cmd string) string {
+	if cmd == "" {
+		return "git"
+	}
+
+	return cmd
+}
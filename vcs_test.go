@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// vcsRunner returns a func running cmd in dir, test is skipped if cmd is not installed.
+func vcsRunner(t *testing.T, cmd string, env ...string) func(dir string, args ...string) string {
+	if _, err := exec.LookPath(cmd); err != nil {
+		t.Skipf("%s is not installed", cmd)
+	}
+
+	return func(dir string, args ...string) string {
+		c := exec.Command(cmd, args...)
+		c.Dir = dir
+		c.Env = append(os.Environ(), env...)
+		out, err := c.CombinedOutput()
+		if err != nil {
+			t.Fatalf("%s %s: %v: %s", cmd, strings.Join(args, " "), err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+}
+
+// testDriverTags checks that driver describes tagged revisions of repo by their tags and the untagged tip
+// by the latest tag.
+func testDriverTags(t *testing.T, driver vcsDriver, repo string) {
+	ctx := context.Background()
+	dir := filepath.Join(t.TempDir(), "clone")
+	if err := driver.Clone(ctx, "example.com/pkg", repo, dir); err != nil {
+		t.Fatalf("Clone() error: %v", err)
+	}
+	if tag, err := driver.Describe(ctx, dir); err != nil || tag != "v1.1.0" {
+		t.Errorf("Describe() of tip = %q, %v, want v1.1.0", tag, err)
+	}
+	tip, err := driver.Revision(ctx, dir)
+	if err != nil || tip == "" {
+		t.Fatalf("Revision() of tip = %q, %v", tip, err)
+	}
+	if _, err := driver.RevisionTime(ctx, dir, tip); err != nil {
+		t.Errorf("RevisionTime() of tip error: %v", err)
+	}
+
+	if err := driver.Checkout(ctx, dir, "v1.0.0"); err != nil {
+		t.Fatalf("Checkout(v1.0.0) error: %v", err)
+	}
+	if tag, err := driver.Describe(ctx, dir); err != nil || tag != "v1.0.0" {
+		t.Errorf("Describe() of v1.0.0 = %q, %v, want v1.0.0", tag, err)
+	}
+	if rev, err := driver.Revision(ctx, dir); err != nil || rev == tip {
+		t.Errorf("Revision() of v1.0.0 = %q, %v, want other than tip", rev, err)
+	}
+
+	tags, err := driver.Tags(ctx, dir)
+	if err != nil {
+		t.Fatalf("Tags() error: %v", err)
+	}
+	for _, want := range []string{"v1.0.0", "v1.1.0"} {
+		if !containsStr(tags, want) {
+			t.Errorf("Tags() = %v, want %s listed", tags, want)
+		}
+	}
+}
+
+func containsStr(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func Test_hgDriver(t *testing.T) {
+	hg := vcsRunner(t, "hg", "HGUSER=ven <ven@example.com>", "HGPLAIN=1")
+	repo := filepath.Join(t.TempDir(), "repo")
+	hg("", "init", repo)
+	for i, tag := range []string{"v1.0.0", "v1.1.0", ""} {
+		writeFile(t, filepath.Join(repo, "a.go"), "package a\n\nconst V = "+string(rune('0'+i))+"\n")
+		hg(repo, "commit", "--addremove", "--message", "commit")
+		if tag != "" {
+			hg(repo, "tag", tag)
+		}
+	}
+
+	testDriverTags(t, hgDriver{}, repo)
+}
+
+func Test_bzrDriver(t *testing.T) {
+	bzr := vcsRunner(t, "bzr", "BZR_EMAIL=ven <ven@example.com>")
+	repo := filepath.Join(t.TempDir(), "repo")
+	bzr("", "init", "--quiet", repo)
+	for i, tag := range []string{"v1.0.0", "v1.1.0", ""} {
+		writeFile(t, filepath.Join(repo, "a.go"), "package a\n\nconst V = "+string(rune('0'+i))+"\n")
+		bzr(repo, "add", "--quiet")
+		bzr(repo, "commit", "--quiet", "--message", "commit")
+		if tag != "" {
+			bzr(repo, "tag", "--quiet", tag)
+		}
+	}
+
+	testDriverTags(t, bzrDriver{}, repo)
+}
+
+func Test_svnDriver(t *testing.T) {
+	svnadmin := vcsRunner(t, "svnadmin")
+	svn := vcsRunner(t, "svn")
+	dir := t.TempDir()
+	repo := filepath.Join(dir, "repo")
+	svnadmin("", "create", repo)
+	url := "file://" + filepath.ToSlash(repo)
+	work := filepath.Join(dir, "work")
+	svn("", "checkout", "--quiet", url, work)
+	writeFile(t, filepath.Join(work, "a.go"), "package a\n")
+	svn(work, "add", "--quiet", "a.go")
+	svn(work, "commit", "--quiet", "--message", "commit a")
+	writeFile(t, filepath.Join(work, "a.go"), "package a\n\nconst V = 1\n")
+	svn(work, "commit", "--quiet", "--message", "commit b")
+
+	ctx := context.Background()
+	driver := svnDriver{}
+	clone := filepath.Join(dir, "clone")
+	if err := driver.Clone(ctx, "example.com/pkg", url, clone); err != nil {
+		t.Fatalf("Clone() error: %v", err)
+	}
+	if rev, err := driver.Revision(ctx, clone); err != nil || rev != "2" {
+		t.Errorf("Revision() = %q, %v, want 2", rev, err)
+	}
+	if err := driver.Checkout(ctx, clone, "1"); err != nil {
+		t.Fatalf("Checkout(1) error: %v", err)
+	}
+	if rev, err := driver.Revision(ctx, clone); err != nil || rev != "1" {
+		t.Errorf("Revision() after Checkout(1) = %q, %v, want 1", rev, err)
+	}
+	if newest, err := newestRevision(ctx, driver, clone, []string{"2", "1"}); err != nil || newest != "2" {
+		t.Errorf("newestRevision() = %q, %v, want 2", newest, err)
+	}
+	if _, err := driver.Describe(ctx, clone); err == nil {
+		t.Error("Describe() error = nil, svn has no tags")
+	}
+}
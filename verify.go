@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Verify checks whether vendor directory matches manifest.
+func Verify(ctx context.Context, verbose bool) error {
+	issues := make(map[string][]string)
+
+	names := make([]string, 0, len(manifest.Packages))
+	for name := range manifest.Packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if ctxCancelled(ctx) {
+			return ctx.Err()
+		}
+
+		info := manifest.Packages[name]
+		if _, err := os.Stat(fmt.Sprintf("%s/%s", manifest.VendorPath, name)); err != nil {
+			issues[name] = append(issues[name], "missing in vendor")
+			continue
+		}
+
+		subpkgs := make([]string, 0, len(info.Subpackages))
+		for subpkg := range info.Subpackages {
+			subpkgs = append(subpkgs, subpkg)
+		}
+		sort.Strings(subpkgs)
+		for _, subpkg := range subpkgs {
+			if _, err := os.Stat(fmt.Sprintf("%s/%s", manifest.VendorPath, subpkg)); err != nil {
+				issues[name] = append(issues[name], fmt.Sprintf("subpackage (%s) missing in vendor", subpkg))
+			}
+		}
+
+		if info.Hash == "" {
+			if verbose {
+				fmt.Printf("pkg (%s): no hash in manifest, contents not verified\n", name)
+			}
+			continue
+		}
+		hash, err := pkgHash(name, info.Pruned)
+		if err != nil {
+			return fmt.Errorf("pkg (%s): cannot compute hash: %v", name, err)
+		}
+		if hash != info.Hash {
+			issues[name] = append(issues[name], fmt.Sprintf("contents changed: manifest hash %s, vendor hash %s", info.Hash, hash))
+		}
+	}
+
+	unlisted, err := getUnlistedPkgs()
+	if err != nil {
+		return err
+	}
+	for _, pkg := range unlisted {
+		issues[pkg] = append(issues[pkg], "not in manifest")
+	}
+
+	if len(issues) == 0 {
+		if verbose {
+			fmt.Println("vendor matches manifest")
+		}
+		return nil
+	}
+
+	pkgs := make([]string, 0, len(issues))
+	for pkg := range issues {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Strings(pkgs)
+	for _, pkg := range pkgs {
+		fmt.Printf("%s:\n", pkg)
+		for _, issue := range issues[pkg] {
+			fmt.Printf("  - %s\n", issue)
+		}
+	}
+
+	return fmt.Errorf("vendor does not match manifest: %d package(s) differ", len(pkgs))
+}
+
+// getUnlistedPkgs returns root packages found in vendor, but missing in manifest.
+// Files in vendor dir itself, like vendor.json of other tools, belong to no package.
+func getUnlistedPkgs() ([]string, error) {
+	unlistedMap := make(map[string]struct{})
+	vendorPath := filepath.Clean(manifest.VendorPath)
+
+	err := filepath.Walk(vendorPath, func(path string, f os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if f.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(vendorPath, filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == "." {
+			return nil
+		}
+		if _, _, exists := manifest.PkgExists(rel); !exists {
+			unlistedMap[getPkgRoot(rel)] = struct{}{}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	unlisted := make([]string, 0, len(unlistedMap))
+	for pkg := range unlistedMap {
+		unlisted = append(unlisted, strings.TrimSuffix(pkg, "/"))
+	}
+	sort.Strings(unlisted)
+
+	return unlisted, nil
+}
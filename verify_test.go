@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	setupProject(t)
+	writeFiles(t, ".", map[string]string{
+		"vendor/github.com/fx/a/a.go":   "package a\n",
+		"vendor/github.com/fx/a/x/x.go": "package x\n",
+		"vendor/github.com/fx/b/b.go":   "package b\n",
+		"vendor/vendor.json":            "{}\n",
+		"vendor/modules.txt":            "# github.com/fx/a\n",
+	})
+	manifest.Packages["github.com/fx/a"] = Package{Subpackages: map[string]struct{}{"github.com/fx/a/x": {}}}
+	manifest.Packages["github.com/fx/b"] = Package{}
+	for _, name := range []string{"github.com/fx/a", "github.com/fx/b"} {
+		hash, err := pkgHash(name, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		info := manifest.Packages[name]
+		info.Hash = hash
+		manifest.Packages[name] = info
+	}
+
+	if err := Verify(context.Background(), false); err != nil {
+		t.Fatalf("Verify() of matching vendor error: %v", err)
+	}
+
+	writeFile(t, "vendor/github.com/fx/b/b.go", "package b\n\nconst Changed = true\n")
+	writeFile(t, "vendor/github.com/fx/u/u.go", "package u\n")
+	if err := os.RemoveAll("vendor/github.com/fx/a/x"); err != nil {
+		t.Fatal(err)
+	}
+	manifest.Packages["github.com/fx/m"] = Package{}
+
+	var verifyErr error
+	out := captureStdout(t, func() {
+		verifyErr = Verify(context.Background(), false)
+	})
+	if verifyErr == nil {
+		t.Fatal("Verify() of changed vendor succeeded")
+	}
+	for _, want := range []string{
+		"github.com/fx/a:\n  - subpackage (github.com/fx/a/x) missing in vendor\n",
+		"github.com/fx/b:\n  - contents changed",
+		"github.com/fx/m:\n  - missing in vendor\n",
+		"github.com/fx/u:\n  - not in manifest\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Verify() output does not contain %q:\n%s", want, out)
+		}
+	}
+	for _, unexpected := range []string{"vendor.json", "modules.txt"} {
+		if strings.Contains(out, unexpected) {
+			t.Errorf("Verify() reports %s:\n%s", unexpected, out)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+)
+
+// importSource describes import statement found in a project file.
+type importSource struct {
+	Pos    token.Position
+	Import string
+}
+
+// Why prints shortest import chains from the project to the target pkg.
+func Why(ctx context.Context, project, target string, verbose bool) error {
+	_, targetRoot, exists := manifest.PkgExists(target)
+	if !exists {
+		return fmt.Errorf("pkg (%s): not found in manifest", target)
+	}
+
+	sources, err := walkImportSources(project, "./", verbose)
+	if err != nil {
+		return fmt.Errorf("failed get imports for a project: %v", err)
+	}
+	sourcesByRoot := make(map[string][]importSource)
+	for _, src := range sources {
+		root := getPkgRoot(src.Import)
+		if _, existingRoot, exists := manifest.PkgExists(src.Import); exists {
+			root = existingRoot
+		}
+		sourcesByRoot[root] = append(sourcesByRoot[root], src)
+	}
+
+	starts := make([]string, 0, len(sourcesByRoot))
+	for root := range sourcesByRoot {
+		starts = append(starts, root)
+	}
+	sort.Strings(starts)
+
+	chains := shortestChains(starts, targetRoot)
+	if len(chains) == 0 {
+		fmt.Printf("pkg (%s) is not required by the project\n", targetRoot)
+		return nil
+	}
+
+	for n, chain := range chains {
+		if n != 0 {
+			fmt.Println()
+		}
+		fmt.Printf("%s -> %s\n", project, strings.Join(chain, " -> "))
+		for _, src := range sourcesByRoot[chain[0]] {
+			fmt.Printf("  %s: import %q\n", src.Pos, src.Import)
+		}
+		for i := 0; i < len(chain)-1; i++ {
+			for _, dep := range pkgImportsOf(chain[i], chain[i+1]) {
+				fmt.Printf("  %s: import %q\n", chain[i], dep)
+			}
+		}
+	}
+
+	return nil
+}
+
+// shortestChains returns every shortest chain of manifest packages from one of starts to the target.
+func shortestChains(starts []string, target string) [][]string {
+	dist := make(map[string]int)
+	parents := make(map[string][]string)
+	queue := make([]string, 0, len(starts))
+	for _, start := range starts {
+		if _, ok := manifest.Packages[start]; !ok {
+			continue
+		}
+		dist[start] = 0
+		queue = append(queue, start)
+	}
+
+	for len(queue) != 0 {
+		pkg := queue[0]
+		queue = queue[1:]
+		if pkg == target {
+			continue
+		}
+
+		for _, dep := range manifest.PkgDepRoots(pkg) {
+			if _, ok := manifest.Packages[dep]; !ok {
+				continue
+			}
+			d, visited := dist[dep]
+			if !visited {
+				dist[dep] = dist[pkg] + 1
+				queue = append(queue, dep)
+			} else if d != dist[pkg]+1 {
+				continue
+			}
+			parents[dep] = append(parents[dep], pkg)
+		}
+	}
+	if _, ok := dist[target]; !ok {
+		return nil
+	}
+
+	var chains [][]string
+	var collect func(pkg string, tail []string)
+	collect = func(pkg string, tail []string) {
+		chain := append([]string{pkg}, tail...)
+		if dist[pkg] == 0 {
+			chains = append(chains, chain)
+			return
+		}
+		for _, parent := range parents[pkg] {
+			collect(parent, chain)
+		}
+	}
+	collect(target, nil)
+
+	sort.Slice(chains, func(i, j int) bool {
+		return strings.Join(chains[i], " ") < strings.Join(chains[j], " ")
+	})
+
+	return chains
+}
+
+// pkgImportsOf returns recorded imports of pkg which belong to the dep.
+func pkgImportsOf(pkg, dep string) []string {
+	var imports []string
+	for i := range manifest.Packages[pkg].Deps {
+		root := getPkgRoot(i)
+		if _, existingRoot, exists := manifest.PkgExists(i); exists {
+			root = existingRoot
+		}
+		if root == dep {
+			imports = append(imports, i)
+		}
+	}
+	sort.Strings(imports)
+
+	return imports
+}
+
+// walkImportSources walks project dir the same way getProjectImports does, but keeps file positions of external imports.
+// Test files are scanned if manifest includes project tests.
+func walkImportSources(pkg, dir string, verbose bool) ([]importSource, error) {
+	fset := token.NewFileSet()
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var sources []importSource
+	for _, f := range files {
+		filePath := path.Join(dir, f.Name())
+		if f.IsDir() {
+			if dirIsExcluded(filePath) {
+				continue
+			}
+			nested, err := walkImportSources(pkg, filePath, verbose)
+			if err != nil {
+				return nil, err
+			}
+			sources = append(sources, nested...)
+			continue
+		}
+		if !strings.HasSuffix(f.Name(), ".go") || (strings.HasSuffix(f.Name(), "_test.go") && !includeProjectTests()) {
+			continue
+		}
+
+		parsedFile, err := parser.ParseFile(fset, filePath, nil, parser.ImportsOnly)
+		if err != nil {
+			if verbose {
+				fmt.Printf("fail parse imports for file (%s): %v\n", filePath, err)
+			}
+			continue
+		}
+
+		scanned, err := importsScanned(filePath, parsedFile, true, verbose)
+		if err != nil {
+			return nil, err
+		}
+		if !scanned {
+			continue
+		}
+
+		for _, i := range parsedFile.Imports {
+			iVal := strings.Replace(i.Path.Value, "\"", "", -1)
+			if classifyImport(pkg, iVal) != importExternal {
+				continue
+			}
+			sources = append(sources, importSource{
+				Pos:    fset.Position(i.Pos()),
+				Import: iVal,
+			})
+		}
+	}
+
+	return sources, nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestWhy(t *testing.T) {
+	setupProject(t)
+	writeFiles(t, ".", map[string]string{
+		"main.go":         "package main\n\nimport _ \"github.com/fx/a\"\n\nfunc main() {}\n",
+		"main_test.go":    "package main\n\nimport _ \"github.com/fx/t\"\n",
+		"main_windows.go": "package main\n\nimport _ \"github.com/fx/x\"\n",
+		"tools/tools.go":  "package tools\n\nimport _ \"github.com/fx/x\"\n",
+	})
+	manifest.ExcludeBuild = map[string]struct{}{"windows": {}}
+	manifest.ExcludeDir = map[string]struct{}{"tools": {}}
+	for _, name := range []string{"github.com/fx/a", "github.com/fx/c", "github.com/fx/t", "github.com/fx/x"} {
+		manifest.Packages[name] = Package{Deps: map[string]struct{}{}, Subpackages: map[string]struct{}{}}
+	}
+	manifest.Packages["github.com/fx/a"].Deps["github.com/fx/c/sub"] = struct{}{}
+
+	tests := []struct {
+		name         string
+		includeTests string
+		target       string
+		want         []string
+	}{
+		{
+			name:   "chain",
+			target: "github.com/fx/c",
+			want: []string{
+				"example.com/proj -> github.com/fx/a -> github.com/fx/c",
+				"main.go:3:8: import \"github.com/fx/a\"",
+				"github.com/fx/a: import \"github.com/fx/c/sub\"",
+			},
+		},
+		{
+			name:         "test import",
+			includeTests: testsProject,
+			target:       "github.com/fx/t",
+			want:         []string{"example.com/proj -> github.com/fx/t", "main_test.go:3:8"},
+		},
+		{
+			name:   "test import without tests",
+			target: "github.com/fx/t",
+			want:   []string{"pkg (github.com/fx/t) is not required by the project"},
+		},
+		{
+			name:   "excluded build and dir",
+			target: "github.com/fx/x",
+			want:   []string{"pkg (github.com/fx/x) is not required by the project"},
+		},
+	}
+	for _, tt := range tests {
+		manifest.IncludeTests = tt.includeTests
+		out := captureStdout(t, func() {
+			if err := Why(context.Background(), "example.com/proj", tt.target, false); err != nil {
+				t.Fatalf("%s: Why() error: %v", tt.name, err)
+			}
+		})
+		for _, want := range tt.want {
+			if !strings.Contains(out, want) {
+				t.Errorf("%s: Why() output does not contain %q:\n%s", tt.name, want, out)
+			}
+		}
+	}
+}